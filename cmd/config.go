@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// config is the JSON file loaded with --config. Every field is optional;
+// unset fields keep the vfs.DefaultOptions value and flags given on the
+// command line override the file.
+//
+//	{
+//	  "max_file_size_mb": 50,
+//	  "max_total_size_mb": 200,
+//	  "compress": true,
+//	  "compression_level": 9,
+//	  "max_access_per_file": 500,
+//	  "anomaly_threshold": 80,
+//	  "mlock": false,
+//	  "verbose": false,
+//	  "sniff_mime": true,
+//	  "session_timeout": "15m",
+//	  "connect_timeout": "30s",
+//	  "shutdown_timeout": "30s",
+//	  "security_preset": "strict",
+//	  "watch": false,
+//	  "include_hidden": false,
+//	  "lazy_tree": false,
+//	  "anonymize_ips": false,
+//	  "mime_type_overrides": {"data/export": "text/csv"},
+//	  "manifest": {"bin/tool": "<sha256 hex>"},
+//	  "exclude": ["node_modules/", "*.log"]
+//	}
+type config struct {
+	MaxFileSizeMB     *int              `json:"max_file_size_mb"`
+	MaxTotalSizeMB    *int              `json:"max_total_size_mb"`
+	Compress          *bool             `json:"compress"`
+	CompressionLevel  *int              `json:"compression_level"`
+	MaxAccessPerFile  *int              `json:"max_access_per_file"`
+	AnomalyThreshold  *int              `json:"anomaly_threshold"`
+	MLock             *bool             `json:"mlock"`
+	Verbose           *bool             `json:"verbose"`
+	SniffMime         *bool             `json:"sniff_mime"`
+	SessionTimeout    *duration         `json:"session_timeout"`
+	ConnectTimeout    *duration         `json:"connect_timeout"`
+	ShutdownTimeout   *duration         `json:"shutdown_timeout"`
+	SecurityPreset    *string           `json:"security_preset"`
+	Watch             *bool             `json:"watch"`
+	IncludeHidden     *bool             `json:"include_hidden"`
+	LazyTree          *bool             `json:"lazy_tree"`
+	AnonymizeIPs      *bool             `json:"anonymize_ips"`
+	MimeTypeOverrides map[string]string `json:"mime_type_overrides"`
+	Manifest          map[string]string `json:"manifest"`
+	Exclude           []string          `json:"exclude"`
+}
+
+// duration reads a time.Duration from a string such as "30m"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// loadConfig reads and decodes a config file, rejecting unknown fields
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cfg config
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// apply copies the fields set in the file onto opts
+func (c *config) apply(opts *vfs.Options) {
+	if c.MaxFileSizeMB != nil {
+		opts.MaxFileSize = int64(*c.MaxFileSizeMB) * 1024 * 1024
+	}
+	if c.MaxTotalSizeMB != nil {
+		opts.MaxTotalSize = int64(*c.MaxTotalSizeMB) * 1024 * 1024
+	}
+	if c.Compress != nil {
+		opts.EnableCompression = *c.Compress
+	}
+	if c.CompressionLevel != nil {
+		opts.CompressionLevel = *c.CompressionLevel
+	}
+	if c.MaxAccessPerFile != nil {
+		opts.MaxAccessPerFile = *c.MaxAccessPerFile
+	}
+	if c.AnomalyThreshold != nil {
+		opts.AnomalyThreshold = *c.AnomalyThreshold
+	}
+	if c.MLock != nil {
+		opts.MLockMemory = *c.MLock
+	}
+	if c.Verbose != nil {
+		opts.Verbose = *c.Verbose
+	}
+	if c.SniffMime != nil {
+		opts.SniffMimeType = *c.SniffMime
+	}
+	if c.SessionTimeout != nil {
+		opts.SessionTimeout = time.Duration(*c.SessionTimeout)
+	}
+	if c.ConnectTimeout != nil {
+		opts.StartupConnectTimeout = time.Duration(*c.ConnectTimeout)
+	}
+	if c.ShutdownTimeout != nil {
+		opts.ShutdownTimeout = time.Duration(*c.ShutdownTimeout)
+	}
+	if c.SecurityPreset != nil {
+		opts.SecurityPreset = *c.SecurityPreset
+	}
+	if c.Watch != nil {
+		opts.Watch = *c.Watch
+	}
+	if c.IncludeHidden != nil {
+		opts.IncludeHidden = *c.IncludeHidden
+	}
+	if c.LazyTree != nil {
+		opts.LazyTree = *c.LazyTree
+	}
+	if c.AnonymizeIPs != nil {
+		opts.AnonymizeIPs = *c.AnonymizeIPs
+	}
+	if c.MimeTypeOverrides != nil {
+		opts.MimeTypeOverrides = c.MimeTypeOverrides
+	}
+	if c.Manifest != nil {
+		opts.Manifest = c.Manifest
+	}
+	if c.Exclude != nil {
+		opts.Exclude = c.Exclude
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// printDryRun writes a --dry-run report: one line per loaded or skipped
+// entry, then a summary
+func printDryRun(w io.Writer, report *vfs.DryRunReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range report.Loaded {
+		fmt.Fprintf(tw, "load\t%s\t%d\t%s\n", f.Path, f.Size, f.MimeType)
+	}
+	for _, s := range report.Skipped {
+		fmt.Fprintf(tw, "skip\t%s\t%s\t\n", s.Path, s.Reason)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d files would be loaded (%.2f MB), %d entries skipped\n",
+		len(report.Loaded), float64(report.TotalSize)/(1024*1024), len(report.Skipped))
+}
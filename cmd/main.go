@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"io"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/oarkflow/previewer/pkg/file"
 	"github.com/oarkflow/previewer/pkg/vfs"
@@ -10,6 +15,7 @@ import (
 
 var (
 	fileFlag        = flag.String("file", "", "Absolute or relative path to the file to preview")
+	urlFlag         = flag.String("url", "", "HTTP(S) URL of a file to download and preview")
 	folderFlag      = flag.String("folder", "", "Absolute or relative path to the folder to preview")
 	maxFileSize     = flag.Int("max-file-size", 100, "Maximum file size in MB (default: 100)")
 	maxTotalSize    = flag.Int("max-total-size", 500, "Maximum total folder size in MB (default: 500)")
@@ -17,8 +23,39 @@ var (
 	maxAccessPerFile = flag.Int("max-access", 1000, "Maximum access attempts per file per minute (default: 1000)")
 	anomalyScore    = flag.Int("anomaly-threshold", 75, "Anomaly detection threshold 0-100 (default: 75)")
 	mlockMemory     = flag.Bool("mlock", false, "Lock memory to prevent swapping (requires privileges)")
+	verbose         = flag.Bool("verbose", false, "Log per-file and per-access details")
+	sniffMime       = flag.Bool("sniff-mime", true, "Detect MIME type from content when the extension is unknown (default: true)")
+	sessionTimeout  = flag.Duration("session-timeout", vfs.DefaultSessionTimeout, "Shut down the folder preview after this long without activity (default: 30m)")
+	nameFlag        = flag.String("name", "stdin", "Display name for content read from stdin; its extension selects the MIME type")
+	mimeFlag        = flag.String("mime", "", "MIME type of content read from stdin, overriding detection from --name and the content")
+	connectTimeout  = flag.Duration("connect-timeout", vfs.DefaultStartupConnectTimeout, "Shut down the folder preview if no browser connects within this long (default: 60s)")
+	shutdownWait    = flag.Duration("shutdown-timeout", vfs.ShutdownTimeout, "How long in-flight downloads get to finish when the folder preview stops (default: 5s)")
+	securityPreset  = flag.String("security", vfs.SecurityPresetStandard, "Security preset for the folder preview: standard or strict")
+	includeHidden   = flag.Bool("hidden", false, "Include dotfiles and dotfolders in the folder preview")
+	lazyTree        = flag.Bool("lazy-tree", false, "Load folder contents on demand instead of embedding the whole tree (for huge folders)")
+	anonymizeIPs    = flag.Bool("anonymize-ips", false, "Log and record client IPs as per-run hashes instead of raw addresses")
+	noRequestLog    = flag.Bool("no-request-log", false, "Don't log every HTTP request of the folder preview")
+	redactPaths     = flag.String("redact-paths", "", "Log file paths as \"basename\" or \"hash\" instead of in full")
+	hostFlag        = flag.String("host", "", "Interface to bind the folder preview to (default 127.0.0.1); e.g. 0.0.0.0 shares it on the network and needs "+basicAuthEnv+"=user:password or a share link")
+	allowIPs        = flag.String("allow-ips", "", "Comma-separated client IPs or CIDR ranges allowed to read files; all others are refused")
+	blockIPs        = flag.String("block-ips", "", "Comma-separated client IPs or CIDR ranges refused outright")
+	lockAfter       = flag.Int("lock-after", 0, "Lock a file, or a client probing invalid paths, for 15m after this many failed accesses (0 disables)")
+	autoBan         = flag.Int("auto-ban", 0, "Ban a client for 10m after this many of its reads trip the anomaly threshold (0 disables)")
+	shareTTL        = flag.Duration("share-ttl", 0, "Log a share link to the folder preview that expires after this long")
+	sharePath       = flag.String("share-path", "", "Limit the --share-ttl link to this file in the folder")
+	manifestFlag    = flag.String("manifest", "", "sha256sum-format file of trusted digests; folder files that don't match are refused")
+	auditLogFlag    = flag.String("audit-log", "", "Append every security incident and file access of the folder preview to this file as JSON lines")
+	compressionLevel = flag.Int("compression-level", 0, "gzip level 1 (fastest load) to 9 (least memory) for compressed text files; 0 uses the gzip default")
+	trustProxy      = flag.Bool("trust-proxy", false, "Take client IPs from the right-most X-Forwarded-For entry (only behind a reverse proxy that sets it)")
+	excludeFlag     = flag.String("exclude", "", "Comma-separated gitignore-style patterns to leave out of the folder preview")
+	watchFlag       = flag.Bool("watch", false, "Reload the folder preview when files change on disk")
+	configFlag      = flag.String("config", "", "JSON file with folder preview options; flags given on the command line override it")
+	dryRunFlag      = flag.Bool("dry-run", false, "List the folder files that would be loaded or skipped, and why, then exit without serving")
 )
 
+// basicAuthEnv holds "user:password" for the folder preview's basic auth
+const basicAuthEnv = "PREVIEWER_BASIC_AUTH"
+
 func main() {
 	flag.Parse()
 
@@ -27,21 +64,82 @@ func main() {
 		log.Fatal("Cannot specify both --file and --folder flags")
 	}
 
-	// Check if neither flag is provided
+	if *dryRunFlag && *folderFlag == "" {
+		log.Fatal("--dry-run requires --folder")
+	}
+
+	if *urlFlag != "" {
+		if *fileFlag != "" || *folderFlag != "" {
+			log.Fatal("Cannot combine --url with --file or --folder")
+		}
+		if err := file.PreviewURL(context.Background(), *urlFlag); err != nil {
+			log.Fatalf("preview URL: %v", err)
+		}
+		return
+	}
+
+	// Check if neither flag is provided; piped input is previewed directly
 	if *fileFlag == "" && *folderFlag == "" {
-		log.Fatal("Either --file or --folder is required")
+		if !stdinIsPiped() {
+			log.Fatal("Either --file, --folder or --url is required (or pipe content on stdin)")
+		}
+		// Bounded like PreviewURL downloads
+		maxSize := vfs.DefaultOptions().MaxFileSize
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, maxSize+1))
+		if err != nil {
+			log.Fatalf("read stdin: %v", err)
+		}
+		defer clear(data)
+		if int64(len(data)) > maxSize {
+			log.Fatalf("read stdin: input exceeds the %d byte limit", maxSize)
+		}
+		if err := file.PreviewBytes(*nameFlag, data, file.PreviewOptions{MimeType: *mimeFlag}); err != nil {
+			log.Fatalf("preview stdin: %v", err)
+		}
+		return
 	}
 
 	// Handle folder preview
 	if *folderFlag != "" {
-		// Configure VFS options
-		opts := vfs.Options{
-			MaxFileSize:       int64(*maxFileSize) * 1024 * 1024,
-			MaxTotalSize:      int64(*maxTotalSize) * 1024 * 1024,
-			EnableCompression: *enableCompress,
-			MaxAccessPerFile:  *maxAccessPerFile,
-			AnomalyThreshold:  *anomalyScore,
-			MLockMemory:       *mlockMemory,
+		// Configure VFS options: flag defaults, then the config file, then
+		// flags given explicitly on the command line
+		opts := vfs.DefaultOptions()
+		applyFlags(&opts, func(name string) bool { return true })
+		if *configFlag != "" {
+			cfg, err := loadConfig(*configFlag)
+			if err != nil {
+				log.Fatalf("load config: %v", err)
+			}
+			cfg.apply(&opts)
+			set := make(map[string]bool)
+			flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+			applyFlags(&opts, func(name string) bool { return set[name] })
+		}
+		if *manifestFlag != "" {
+			manifest, err := vfs.ReadManifest(*manifestFlag)
+			if err != nil {
+				log.Fatalf("read manifest: %v", err)
+			}
+			opts.Manifest = manifest
+		}
+		if *auditLogFlag != "" && !*dryRunFlag {
+			auditLog, err := os.OpenFile(*auditLogFlag, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+			if err != nil {
+				log.Fatalf("open audit log: %v", err)
+			}
+			defer auditLog.Close()
+			opts.AuditLogWriter = auditLog
+		}
+		if err := validateOptions(opts); err != nil {
+			log.Fatalf("invalid options: %v", err)
+		}
+		if *dryRunFlag {
+			report, err := vfs.DryRun(*folderFlag, opts)
+			if err != nil {
+				log.Fatalf("dry run: %v", err)
+			}
+			printDryRun(os.Stdout, report)
+			return
 		}
 		if err := file.PreviewFolderWithOptions(*folderFlag, opts); err != nil {
 			log.Fatalf("preview folder: %v", err)
@@ -54,3 +152,153 @@ func main() {
 		log.Fatalf("preview file: %v", err)
 	}
 }
+
+// applyFlags copies the flags selected by use onto opts
+func applyFlags(opts *vfs.Options, use func(name string) bool) {
+	if use("max-file-size") {
+		opts.MaxFileSize = int64(*maxFileSize) * 1024 * 1024
+	}
+	if use("max-total-size") {
+		opts.MaxTotalSize = int64(*maxTotalSize) * 1024 * 1024
+	}
+	if use("compress") {
+		opts.EnableCompression = *enableCompress
+	}
+	if use("compression-level") {
+		opts.CompressionLevel = *compressionLevel
+	}
+	if use("max-access") {
+		opts.MaxAccessPerFile = *maxAccessPerFile
+	}
+	if use("anomaly-threshold") {
+		opts.AnomalyThreshold = *anomalyScore
+	}
+	if use("mlock") {
+		opts.MLockMemory = *mlockMemory
+	}
+	if use("verbose") {
+		opts.Verbose = *verbose
+	}
+	if use("sniff-mime") {
+		opts.SniffMimeType = *sniffMime
+	}
+	if use("session-timeout") {
+		opts.SessionTimeout = *sessionTimeout
+	}
+	if use("connect-timeout") {
+		opts.StartupConnectTimeout = *connectTimeout
+	}
+	if use("shutdown-timeout") {
+		opts.ShutdownTimeout = *shutdownWait
+	}
+	if use("hidden") {
+		opts.IncludeHidden = *includeHidden
+	}
+	if use("lazy-tree") {
+		opts.LazyTree = *lazyTree
+	}
+	if use("anonymize-ips") {
+		opts.AnonymizeIPs = *anonymizeIPs
+	}
+	if use("no-request-log") {
+		opts.DisableRequestLogging = *noRequestLog
+	}
+	if use("redact-paths") {
+		opts.RedactPaths = *redactPaths
+	}
+	if use("host") {
+		opts.ListenHost = *hostFlag
+	}
+	if use("allow-ips") && *allowIPs != "" {
+		opts.AllowedIPs = strings.Split(*allowIPs, ",")
+	}
+	if use("block-ips") && *blockIPs != "" {
+		opts.BlockedIPs = strings.Split(*blockIPs, ",")
+	}
+	if use("lock-after") {
+		opts.LockAfterFailures = *lockAfter
+	}
+	if use("auto-ban") {
+		opts.AutoBanAfter = *autoBan
+	}
+	if use("share-ttl") {
+		opts.ShareTTL = *shareTTL
+	}
+	if use("share-path") {
+		opts.SharePath = *sharePath
+	}
+	// Credentials come from the environment so they don't show up in ps
+	if user, password, ok := strings.Cut(os.Getenv(basicAuthEnv), ":"); ok {
+		opts.BasicAuthUser, opts.BasicAuthPassword = user, password
+	}
+	if use("exclude") && *excludeFlag != "" {
+		opts.Exclude = strings.Split(*excludeFlag, ",")
+	}
+	if use("watch") {
+		opts.Watch = *watchFlag
+	}
+	if use("trust-proxy") {
+		opts.TrustProxyHeaders = *trustProxy
+	}
+	if use("security") {
+		opts.SecurityPreset = *securityPreset
+	}
+}
+
+// validateOptions rejects out-of-range values before anything is loaded
+func validateOptions(opts vfs.Options) error {
+	var errs []error
+	if opts.MaxFileSize <= 0 {
+		errs = append(errs, errors.New("--max-file-size must be a positive number of MB"))
+	}
+	if opts.MaxTotalSize <= 0 {
+		errs = append(errs, errors.New("--max-total-size must be a positive number of MB"))
+	}
+	if opts.MaxFileSize > opts.MaxTotalSize {
+		errs = append(errs, errors.New("--max-file-size cannot exceed --max-total-size"))
+	}
+	if opts.MaxAccessPerFile < 1 {
+		errs = append(errs, errors.New("--max-access must be at least 1"))
+	}
+	if opts.AnomalyThreshold < 0 || opts.AnomalyThreshold > 100 {
+		errs = append(errs, errors.New("--anomaly-threshold must be between 0 and 100"))
+	}
+	if opts.SessionTimeout < 0 {
+		errs = append(errs, errors.New("--session-timeout cannot be negative"))
+	}
+	if opts.LockAfterFailures < 0 {
+		errs = append(errs, errors.New("--lock-after cannot be negative"))
+	}
+	if opts.AutoBanAfter < 0 {
+		errs = append(errs, errors.New("--auto-ban cannot be negative"))
+	}
+	if opts.StartupConnectTimeout < 0 {
+		errs = append(errs, errors.New("--connect-timeout cannot be negative"))
+	}
+	if opts.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("--shutdown-timeout cannot be negative"))
+	}
+	if opts.CompressionLevel < 0 || opts.CompressionLevel > 9 {
+		errs = append(errs, errors.New("--compression-level must be between 1 and 9, or 0 for the default"))
+	}
+	switch opts.SecurityPreset {
+	case "", vfs.SecurityPresetStandard, vfs.SecurityPresetStrict:
+	default:
+		errs = append(errs, errors.New("--security must be standard or strict"))
+	}
+	switch opts.RedactPaths {
+	case "", vfs.RedactPathsBasename, vfs.RedactPathsHash:
+	default:
+		errs = append(errs, errors.New("--redact-paths must be basename or hash"))
+	}
+	return errors.Join(errs...)
+}
+
+// stdinIsPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
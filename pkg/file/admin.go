@@ -0,0 +1,148 @@
+package file
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isAdminRoute reports whether path is an operator endpoint, authenticated
+// by requireAdmin rather than the preview session or basic auth
+func isAdminRoute(path string) bool {
+	return path == "/api/file-stats" || path == "/api/unlock"
+}
+
+// requireAdmin guards operator endpoints. They are disabled unless
+// Options.AdminToken is set, and then need "Authorization: Bearer <token>".
+// It writes the error and returns false if the request is refused.
+func (s *previewServer) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.options.AdminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.options.AdminToken)) != 1 {
+		s.logSecurityIncident("admin_denied", "medium", "Admin request with missing or invalid token", map[string]any{
+			"path": r.URL.Path,
+			"ip":   s.clientIP(r),
+		})
+		http.Error(w, "Access denied", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// fileStats is the /api/file-stats response
+type fileStats struct {
+	Path            string         `json:"path"`
+	Size            int64          `json:"size"`
+	StoredSize      int64          `json:"storedSize"` // Bytes in memory, encrypted and possibly compressed
+	Compressed      bool           `json:"compressed"`
+	AccessCount     int            `json:"accessCount"`
+	FailedAttempts  int            `json:"failedAttempts"`
+	FirstAccess     time.Time      `json:"firstAccess"`
+	LastAccess      time.Time      `json:"lastAccess"`
+	UniqueIPs       int            `json:"uniqueIps"`
+	IPAddresses     map[string]int `json:"ipAddresses,omitempty"` // Only with Options.StatsIncludeIPs
+	OtherIPAccesses int            `json:"otherIpAccesses"`       // Accesses from IPs no longer tracked individually
+	AnomalyScore    float64        `json:"anomalyScore"`
+	SuspiciousFlags []string       `json:"suspiciousFlags"`
+	LockedUntil     *time.Time     `json:"lockedUntil,omitempty"` // Set while Options.LockAfterFailures refuses the file
+}
+
+// handleFileStats returns the access record of one file, for operators
+// investigating a suspicious file while the preview is running
+func (s *previewServer) handleFileStats(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if err := s.vfs.ValidatePath(filePath); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	mount, mountPath, ok := s.resolve(filePath)
+	if !ok {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	info, exists := mount.vfs.Info(mountPath)
+	if !exists {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	record, _ := mount.vfs.AccessRecord(mountPath)
+	stats := fileStats{
+		Path:            mount.fullPath(record.Path),
+		Size:            info.Size,
+		StoredSize:      info.StoredSize,
+		Compressed:      info.Compressed,
+		AccessCount:     record.AccessCount,
+		FailedAttempts:  record.FailedAttempts,
+		FirstAccess:     record.FirstAccess,
+		LastAccess:      record.LastAccess,
+		UniqueIPs:       record.UniqueIPs,
+		OtherIPAccesses: record.OtherIPAccesses,
+		AnomalyScore:    record.AnomalyScore,
+		SuspiciousFlags: record.SuspiciousFlags,
+	}
+	if s.options.StatsIncludeIPs {
+		stats.IPAddresses = record.IPAddresses
+	}
+	if time.Now().Before(record.LockedUntil) {
+		stats.LockedUntil = &record.LockedUntil
+	}
+	if stats.SuspiciousFlags == nil {
+		stats.SuspiciousFlags = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// handleUnlock lifts a LockAfterFailures lock early: POST /api/unlock with
+// ?path= for a file or ?ip= for a client (as it appears in the logs). It
+// answers 404 if nothing was locked.
+func (s *previewServer) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var unlocked bool
+	switch {
+	case query.Get("path") != "":
+		if mount, mountPath, ok := s.resolve(query.Get("path")); ok {
+			unlocked = mount.vfs.Unlock(mountPath)
+		}
+	case query.Get("ip") != "":
+		for _, fs := range s.allVFS() {
+			unlocked = fs.UnlockIP(query.Get("ip")) || unlocked
+		}
+	default:
+		http.Error(w, "Missing path or ip", http.StatusBadRequest)
+		return
+	}
+	if !unlocked {
+		http.Error(w, "Not locked", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
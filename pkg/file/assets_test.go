@@ -0,0 +1,52 @@
+package file
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestMissingIndexHTML(t *testing.T) {
+	options := vfs.DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.AssetsFS = fstest.MapFS{
+		"assets/app.js": &fstest.MapFile{Data: []byte("console.log(1)")},
+	}
+
+	if _, _, err := loadAssets(options); err == nil || !strings.Contains(err.Error(), "index.html") {
+		t.Fatalf("loadAssets error = %v, want one about index.html", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := vfs.NewVirtualFileSystemWithOptions(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+	meta, err := buildFolderStructure(options, fs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newPreviewServerFromFolder(dir, meta, fs, options); err == nil {
+		t.Fatal("newPreviewServerFromFolder succeeded without index.html")
+	}
+}
+
+func TestSPAHandlerWithoutAssets(t *testing.T) {
+	srv := &previewServer{logger: slog.New(slog.DiscardHandler)}
+	rec := httptest.NewRecorder()
+	srv.spaHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+}
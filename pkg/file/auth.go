@@ -0,0 +1,91 @@
+package file
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// authRequired reports whether requests must carry the basic auth
+// credentials: always for a remote-bound server, and on loopback only with
+// Options.RequireAuth
+func (s *previewServer) authRequired() bool {
+	return s.remote || s.options.RequireAuth
+}
+
+// checkAuthOptions refuses configurations that would serve decrypted files
+// without credentials to anyone on the network. Share links count as
+// credentials.
+func checkAuthOptions(options vfs.Options) error {
+	remote := !isLoopbackHost(options.ListenHost)
+	hasBasicAuth := options.BasicAuthUser != "" && options.BasicAuthPassword != ""
+	if (remote || options.RequireAuth) && !hasBasicAuth && len(options.ShareSecret) == 0 {
+		if remote {
+			return errors.New("listening on a non-loopback host requires BasicAuthUser and BasicAuthPassword, or share links")
+		}
+		return errors.New("RequireAuth needs BasicAuthUser and BasicAuthPassword, or share links")
+	}
+	return nil
+}
+
+// withAuth enforces HTTP basic auth on every route except the health
+// checks, which hold no data. A valid share token is accepted instead and
+// limits the request to its scope. Admin requests authenticate with their
+// own bearer token.
+func (s *previewServer) withAuth(next http.Handler) http.Handler {
+	if !s.authRequired() && len(s.options.ShareSecret) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if scope, ok := s.checkShareToken(w, r); ok {
+			if !shareAllows(scope, r) {
+				s.logSecurityIncident("share_scope_denied", "medium", "Request outside the share link's scope", map[string]any{
+					"route": r.URL.Path,
+					"ip":    s.clientIP(r),
+				})
+				http.Error(w, "Access denied", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withShareScope(r.Context(), scope)))
+			return
+		}
+		switch {
+		case !s.authRequired():
+		case isAdminRoute(r.URL.Path) && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "):
+		case !s.validBasicAuth(r):
+			if _, _, sent := r.BasicAuth(); sent {
+				s.logSecurityIncident("auth_failed", "medium", "Invalid basic auth credentials", map[string]any{
+					"route": r.URL.Path,
+					"ip":    s.clientIP(r),
+				})
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", "previewer"))
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validBasicAuth compares the credentials in constant time. Both sides are
+// hashed first so the comparison does not leak their lengths.
+func (s *previewServer) validBasicAuth(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok || s.options.BasicAuthUser == "" || s.options.BasicAuthPassword == "" {
+		return false
+	}
+	gotUser, wantUser := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(s.options.BasicAuthUser))
+	gotPass, wantPass := sha256.Sum256([]byte(password)), sha256.Sum256([]byte(s.options.BasicAuthPassword))
+	userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:])
+	passOK := subtle.ConstantTimeCompare(gotPass[:], wantPass[:])
+	return userOK&passOK == 1
+}
@@ -0,0 +1,143 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrBrowserUnavailable is returned when no browser launcher could be found
+// or started; the preview URL has to be opened manually
+var ErrBrowserUnavailable = errors.New("no browser available")
+
+// browserLaunchWait is how long a launcher gets to fail before it is
+// assumed to have handed the URL to a browser
+const browserLaunchWait = time.Second
+
+// openBrowser opens url using $BROWSER if set, the Windows side of WSL when
+// running there, or the platform's default opener.
+func openBrowser(url string) error {
+	if env := os.Getenv("BROWSER"); env != "" {
+		var errs []error
+		// $BROWSER is a colon-separated list of commands; "%s" marks where
+		// the URL goes, otherwise it is appended
+		for _, entry := range strings.Split(env, string(os.PathListSeparator)) {
+			fields := strings.Fields(entry)
+			if len(fields) == 0 {
+				continue
+			}
+			args, substituted := fields[1:], false
+			for i, arg := range args {
+				if strings.Contains(arg, "%s") {
+					args[i] = strings.ReplaceAll(arg, "%s", url)
+					substituted = true
+				}
+			}
+			if !substituted {
+				args = append(args, url)
+			}
+			err := launch(fields[0], args...)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%w: $BROWSER: %w", ErrBrowserUnavailable, errors.Join(errs...))
+		}
+	}
+
+	if isWSL() {
+		if _, err := lookPath("wslview"); err == nil {
+			return launch("wslview", url)
+		}
+		return launch("powershell.exe", "-NoProfile", "-Command", "Start-Process", "'"+strings.ReplaceAll(url, "'", "''")+"'")
+	}
+
+	var cmd string
+	var args []string
+
+	switch goos {
+	case "darwin":
+		cmd = "open"
+		args = []string{url}
+	case "windows":
+		cmd = "rundll32"
+		args = []string{"url.dll,FileProtocolHandler", url}
+	default:
+		cmd = "xdg-open"
+		args = []string{url}
+	}
+
+	return launch(cmd, args...)
+}
+
+// launch runs a browser launcher, failing fast with ErrBrowserUnavailable
+// when it is not installed or does not start
+func launch(cmd string, args ...string) error {
+	if _, err := lookPath(cmd); err != nil {
+		return fmt.Errorf("%w: %w", ErrBrowserUnavailable, err)
+	}
+	if err := execCommand(cmd, args...); err != nil {
+		return fmt.Errorf("%w: %w", ErrBrowserUnavailable, err)
+	}
+	return nil
+}
+
+// announceURL reports a failed browser launch and prints the URL prominently
+// so it can be opened manually
+func (s *previewServer) announceURL(url string, err error) {
+	if err == nil {
+		return
+	}
+	s.logger.Warn("open browser", "error", err)
+	fmt.Fprintf(os.Stderr, "\n  Could not open a browser automatically.\n  Open this URL to view the preview:\n\n    %s\n\n", url)
+}
+
+// isWSL reports whether we run under the Windows Subsystem for Linux
+func isWSL() bool {
+	if goos != "linux" {
+		return false
+	}
+	version, err := os.ReadFile(procVersionPath)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(version)), "microsoft")
+}
+
+// goos and procVersionPath pick the launcher; like execCommand, variables
+// so tests can choose the platform
+var (
+	goos            = runtime.GOOS
+	procVersionPath = "/proc/version"
+)
+
+// lookPath finds launchers; like execCommand, a variable so it can be stubbed
+var lookPath = exec.LookPath
+
+// execCommand starts a launcher and reports a failure if it exits non-zero
+// within browserLaunchWait. Launchers that keep running are left alone. It
+// is a variable, like the signalNotify seam, so tests can record the
+// command instead of opening a browser.
+var execCommand = func(cmd string, args ...string) error {
+	c := exec.Command(cmd, args...)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd, err)
+		}
+		return nil
+	case <-time.After(browserLaunchWait):
+		return nil
+	}
+}
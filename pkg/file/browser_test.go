@@ -0,0 +1,91 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// stubLaunchers records launched commands instead of running them, for the
+// given platform; only the launchers in installed are found
+func stubLaunchers(t *testing.T, platform, procVersion string, installed ...string) *[][]string {
+	t.Helper()
+	oldGOOS, oldProc, oldLook, oldExec := goos, procVersionPath, lookPath, execCommand
+	t.Cleanup(func() {
+		goos, procVersionPath, lookPath, execCommand = oldGOOS, oldProc, oldLook, oldExec
+	})
+
+	goos = platform
+	procVersionPath = filepath.Join(t.TempDir(), "version")
+	if err := os.WriteFile(procVersionPath, []byte(procVersion), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lookPath = func(cmd string) (string, error) {
+		if slices.Contains(installed, cmd) {
+			return "/usr/bin/" + cmd, nil
+		}
+		return "", errors.New("not found")
+	}
+	var launched [][]string
+	execCommand = func(cmd string, args ...string) error {
+		launched = append(launched, append([]string{cmd}, args...))
+		return nil
+	}
+	return &launched
+}
+
+func TestOpenBrowserPerGOOS(t *testing.T) {
+	const url = "http://localhost:8080/?folder=docs"
+	tests := []struct {
+		name        string
+		goos        string
+		procVersion string
+		installed   []string
+		want        []string
+	}{
+		{"darwin", "darwin", "", []string{"open"}, []string{"open", url}},
+		{"windows", "windows", "", []string{"rundll32"}, []string{"rundll32", "url.dll,FileProtocolHandler", url}},
+		{"linux", "linux", "Linux version 6.1.0", []string{"xdg-open"}, []string{"xdg-open", url}},
+		{"freebsd", "freebsd", "", []string{"xdg-open"}, []string{"xdg-open", url}},
+		{"wsl with wslview", "linux", "Linux version 5.15-microsoft-standard-WSL2", []string{"wslview", "powershell.exe"}, []string{"wslview", url}},
+		{"wsl", "linux", "Linux version 5.15-Microsoft", []string{"powershell.exe"}, []string{"powershell.exe", "-NoProfile", "-Command", "Start-Process", "'" + url + "'"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BROWSER", "")
+			launched := stubLaunchers(t, tt.goos, tt.procVersion, tt.installed...)
+			if err := openBrowser(url); err != nil {
+				t.Fatalf("openBrowser: %v", err)
+			}
+			if len(*launched) != 1 || !slices.Equal((*launched)[0], tt.want) {
+				t.Fatalf("launched %q, want %q", *launched, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBrowserEnv(t *testing.T) {
+	const url = "http://localhost:8080/"
+	t.Setenv("BROWSER", "missing-browser"+string(os.PathListSeparator)+"firefox --new-tab %s")
+	launched := stubLaunchers(t, "linux", "", "firefox")
+	if err := openBrowser(url); err != nil {
+		t.Fatalf("openBrowser: %v", err)
+	}
+	want := []string{"firefox", "--new-tab", url}
+	if len(*launched) != 1 || !slices.Equal((*launched)[0], want) {
+		t.Fatalf("launched %q, want %q", *launched, want)
+	}
+}
+
+func TestOpenBrowserUnavailable(t *testing.T) {
+	t.Setenv("BROWSER", "")
+	launched := stubLaunchers(t, "linux", "")
+	if err := openBrowser("http://localhost:8080/"); !errors.Is(err, ErrBrowserUnavailable) {
+		t.Fatalf("openBrowser error = %v, want ErrBrowserUnavailable", err)
+	}
+	if len(*launched) != 0 {
+		t.Fatalf("launched %q without any launcher installed", *launched)
+	}
+}
@@ -0,0 +1,46 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/oarkflow/previewer/pkg/render"
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// convertsDocuments reports whether a real document converter is configured
+func (s *previewServer) convertsDocuments() bool {
+	if s.options.Converter == nil {
+		return false
+	}
+	_, noop := s.options.Converter.(render.NoopDocumentConverter)
+	return !noop
+}
+
+// convertible reports whether a file is previewed through the converter
+func (s *previewServer) convertible(info vfs.FileInfo) bool {
+	return s.convertsDocuments() && render.IsOfficeType(info.MimeType)
+}
+
+// convertToPDF converts an office document for the viewer. The result is
+// only embedded into the page; the VFS keeps the original bytes.
+func (s *previewServer) convertToPDF(ctx context.Context, vfile *vfs.VirtualFile) (*vfs.VirtualFile, error) {
+	pdf, err := s.options.Converter.ToPDF(ctx, vfile.MimeType, vfile.Data)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s to PDF: %w", vfile.MimeType, err)
+	}
+	hash := sha256.Sum256(pdf)
+	return &vfs.VirtualFile{
+		Path:     vfile.Path,
+		Name:     strings.TrimSuffix(vfile.Name, filepath.Ext(vfile.Name)) + ".pdf",
+		Data:     pdf,
+		Size:     int64(len(pdf)),
+		MimeType: "application/pdf",
+		Hash:     hex.EncodeToString(hash[:]),
+		ModTime:  vfile.ModTime,
+	}, nil
+}
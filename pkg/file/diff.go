@@ -0,0 +1,466 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxDiffBytes = 1024 * 1024    // Largest file /api/diff compares
+const diffContext = 3               // Unchanged lines around each hunk
+const diffTimeout = 2 * time.Second // After this the diff stops looking for the shortest edit script
+
+// diffableTypes are the MIME types (or prefixes ending in "/") /api/diff
+// compares; anything else gets 415
+var diffableTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+}
+
+// handleDiff compares two text files of the folder: GET
+// /api/diff?a=...&b=... returns {"a", "b", "identical", "diff"} with a
+// unified diff, or the diff alone as text/plain with &format=text. Both
+// files are read with the requester's permissions and access tracking.
+func (s *previewServer) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	pathA, pathB := query.Get("a"), query.Get("b")
+	if pathA == "" || pathB == "" {
+		http.Error(w, "Missing a or b file path", http.StatusBadRequest)
+		return
+	}
+	if !s.requirePreviewSession(w, r) {
+		return
+	}
+
+	var texts [2][]byte
+	for i, filePath := range []string{pathA, pathB} {
+		data, status, err := s.readDiffable(r, filePath)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		defer clear(data)
+		texts[i] = data
+	}
+	s.touch()
+
+	// The diff holds file content: it is built in one buffer that is sized
+	// up front, so it never leaves grown-out copies behind, and cleared once
+	// written. No string copies of it are made.
+	var diff bytes.Buffer
+	defer func() { clear(diff.Bytes()[:diff.Cap()]) }()
+	unifiedDiff(r.Context(), &diff, "a/"+strings.TrimPrefix(pathA, "/"), "b/"+strings.TrimPrefix(pathB, "/"), texts[0], texts[1])
+	w.Header().Set("Cache-Control", "no-store")
+	if query.Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(diff.Bytes())
+		return
+	}
+	names, err := json.Marshal(map[string]any{"a": pathA, "b": pathB, "identical": diff.Len() == 0})
+	if err != nil {
+		http.Error(w, "Failed to encode diff", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(names[:len(names)-1])
+	_, _ = io.WriteString(w, `,"diff":`)
+	_ = writeJSONString(w, diff.Bytes())
+	_, _ = io.WriteString(w, "}\n")
+}
+
+// writeJSONString writes text, which must be valid UTF-8, to w as a JSON
+// string escaped the way encoding/json escapes strings. It goes through a
+// small buffer that is cleared afterwards instead of an escaped copy.
+func writeJSONString(w io.Writer, text []byte) error {
+	const hex = "0123456789abcdef"
+	buf := make([]byte, 0, 4096)
+	defer func() { clear(buf[:cap(buf)]) }()
+	buf = append(buf, '"')
+	for i := 0; i < len(text); i++ {
+		if len(buf) > cap(buf)-8 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+		switch c := text[i]; {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, '\\', 'n')
+		case c == '\r':
+			buf = append(buf, '\\', 'r')
+		case c == '\t':
+			buf = append(buf, '\\', 't')
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		case c == 0xe2 && i+2 < len(text) && text[i+1] == 0x80 && (text[i+2] == 0xa8 || text[i+2] == 0xa9):
+			// U+2028 and U+2029 break JavaScript string literals
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[text[i+2]&0xf])
+			i += 2
+		default:
+			buf = append(buf, c)
+		}
+	}
+	buf = append(buf, '"')
+	_, err := w.Write(buf)
+	return err
+}
+
+// readDiffable decrypts one side of a diff, checking its type and size
+// first. On error it also returns the status to answer with.
+func (s *previewServer) readDiffable(r *http.Request, filePath string) ([]byte, int, error) {
+	const denied = "Access denied or file not found"
+	clientIP, remoteIP := s.clientIP(r), s.requestIP(r)
+	mount, mountPath, ok := s.resolve(filePath)
+	if !ok {
+		return nil, http.StatusForbidden, errors.New(denied)
+	}
+	info, err := mount.vfs.StatWithIP(mountPath, remoteIP)
+	if err != nil {
+		s.logger.Warn("VFS stat error", "path", filePath, "ip", clientIP, "error", err)
+		return nil, http.StatusForbidden, errors.New(denied)
+	}
+	if !diffable(info.MimeType) {
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("Only text files can be compared, not %s", info.MimeType)
+	}
+	if info.Size > maxDiffBytes {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("Files over %d MB cannot be compared", maxDiffBytes/(1024*1024))
+	}
+
+	vfile, err := mount.vfs.ReadFileContext(r.Context(), mountPath, remoteIP)
+	if errors.Is(err, context.Canceled) {
+		return nil, 0, err
+	}
+	if err != nil {
+		s.logger.Warn("VFS read error", "path", filePath, "ip", clientIP, "error", err)
+		return nil, http.StatusForbidden, errors.New(denied)
+	}
+	// The MIME type can come from the extension; binary content is refused
+	if !utf8.Valid(vfile.Data) || bytes.IndexByte(vfile.Data, 0) >= 0 {
+		clear(vfile.Data)
+		return nil, http.StatusUnsupportedMediaType, errors.New("Only text files can be compared")
+	}
+	return vfile.Data, 0, nil
+}
+
+// diffable reports whether /api/diff compares files of this MIME type
+func diffable(mimeType string) bool {
+	for _, t := range diffableTypes {
+		if strings.HasPrefix(mimeType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// unifiedDiff writes the lines of a and b to out as a unified diff with the
+// given file names, or nothing if they are identical. If ctx ends or
+// diffTimeout passes before the shortest edit script is found, the rest of
+// the diff is still correct but may be longer than necessary. out is grown
+// once, to a bound on the diff's length, before anything is written.
+func unifiedDiff(ctx context.Context, out *bytes.Buffer, nameA, nameB string, a, b []byte) {
+	linesA, linesB := splitLines(a), splitLines(b)
+	ops := diffLines(ctx, linesA, linesB)
+
+	var hunks [][2]int // [lo, hi) ranges of ops
+	for start := 0; start < len(ops); {
+		// Find the next change, then extend the hunk while the following
+		// changes are close enough to share context
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for next := first; next < len(ops); next++ {
+			if ops[next].kind == ' ' {
+				continue
+			}
+			if next-last > 2*diffContext {
+				break
+			}
+			last = next
+		}
+		lo := max(first-diffContext, 0)
+		hi := min(last+diffContext+1, len(ops))
+		hunks = append(hunks, [2]int{lo, hi})
+		start = hi
+	}
+	if len(hunks) == 0 {
+		return
+	}
+
+	// File headers, at most two missing-newline markers, hunk headers of at
+	// most 9+2*41 bytes and the lines with their prefix
+	const noNewline = "\n\\ No newline at end of file\n"
+	size := len(nameA) + len(nameB) + 10 + 2*len(noNewline) + len(hunks)*91
+	for _, h := range hunks {
+		for _, op := range ops[h[0]:h[1]] {
+			size += 1 + len(op.text)
+		}
+	}
+	out.Grow(size)
+
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", nameA, nameB)
+	for _, h := range hunks {
+		lo, hi := h[0], h[1]
+		var countA, countB int
+		for _, op := range ops[lo:hi] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[lo].lineA, countA), hunkRange(ops[lo].lineB, countB))
+		for _, op := range ops[lo:hi] {
+			out.WriteByte(op.kind)
+			out.Write(op.text)
+			if !bytes.HasSuffix(op.text, []byte("\n")) {
+				out.WriteString(noNewline)
+			}
+		}
+	}
+}
+
+// hunkRange formats the start,count of a hunk side; start is the 0-based
+// line the hunk begins at
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text after each newline, so a last line without one
+// differs from the same line with one. The lines are subslices of text, so
+// clearing text clears them.
+func splitLines(text []byte) [][]byte {
+	var lines [][]byte
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed from a or
+// '+' added from b. lineA and lineB are the 0-based positions in a and b
+// before the line.
+type diffOp struct {
+	kind         byte
+	text         []byte
+	lineA, lineB int
+}
+
+// diffLines returns the edit script turning a into b, using Myers'
+// linear-space algorithm on interned lines. Lines are interned by hash, with
+// the bytes compared on collision, so no string copies of them are made.
+func diffLines(ctx context.Context, a, b [][]byte) []diffOp {
+	seed := maphash.MakeSeed()
+	buckets := make(map[uint64][]int) // Line hash -> ids
+	var distinct [][]byte             // Id -> first line with it
+	intern := func(lines [][]byte) []int {
+		out := make([]int, len(lines))
+	next:
+		for i, line := range lines {
+			h := maphash.Bytes(seed, line)
+			for _, id := range buckets[h] {
+				if bytes.Equal(distinct[id], line) {
+					out[i] = id
+					continue next
+				}
+			}
+			out[i] = len(distinct)
+			buckets[h] = append(buckets[h], len(distinct))
+			distinct = append(distinct, line)
+		}
+		return out
+	}
+	d := &differ{
+		ctx:      ctx,
+		deadline: time.Now().Add(diffTimeout),
+		a:        intern(a),
+		b:        intern(b),
+		removed:  make([]bool, len(a)),
+		added:    make([]bool, len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.removed[i]:
+			ops = append(ops, diffOp{kind: '-', text: a[i], lineA: i, lineB: j})
+			i++
+		case j < len(b) && d.added[j]:
+			ops = append(ops, diffOp{kind: '+', text: b[j], lineA: i, lineB: j})
+			j++
+		default:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], lineA: i, lineB: j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// differ marks the lines of a removed and of b added by an edit script
+type differ struct {
+	ctx      context.Context
+	deadline time.Time
+	a, b     []int
+	removed  []bool
+	added    []bool
+}
+
+// compare marks the edits turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	if aLo == aHi || bLo == bHi {
+		for i := aLo; i < aHi; i++ {
+			d.removed[i] = true
+		}
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+		return
+	}
+	x, y, ok := d.middleSnake(aLo, aHi, bLo, bHi)
+	if !ok {
+		// No common line found (or out of time): replace the whole range
+		for i := aLo; i < aHi; i++ {
+			d.removed[i] = true
+		}
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+		return
+	}
+	d.compare(aLo, x, bLo, y)
+	d.compare(x, aHi, y, bHi)
+}
+
+// middleSnake walks forward from the start and backward from the end of
+// the ranges until the paths overlap, and returns a point on a shortest
+// edit path there
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	v1 := make([]int, 2*maxD+2)
+	v2 := make([]int, 2*maxD+2)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0
+	var k1start, k1end, k2start, k2end int
+
+	for step := 0; step < maxD; step++ {
+		if d.ctx.Err() != nil || time.Now().After(d.deadline) {
+			return 0, 0, false
+		}
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			i := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[i-1] < v1[i+1]) {
+				x1 = v1[i+1]
+			} else {
+				x1 = v1[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[i] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				j := offset + delta - k1
+				if j >= 0 && j < len(v2) && v2[j] != -1 && x1 >= n-v2[j] {
+					return aLo + x1, bLo + y1, true
+				}
+			}
+		}
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			i := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[i-1] < v2[i+1]) {
+				x2 = v2[i+1]
+			} else {
+				x2 = v2[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[i] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				j := offset + delta - k2
+				if j >= 0 && j < len(v1) && v1[j] != -1 {
+					x1 := v1[j]
+					y1 := offset + x1 - j
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
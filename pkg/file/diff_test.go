@@ -0,0 +1,82 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestDiffEndpoint(t *testing.T) {
+	a := "one\ntwo\nthree\n<four> & \"five\"\n"
+	b := "one\n2\nthree\n<four> & \"five\"\tsix\u2028"
+	srv := newTestFolderServer(t, map[string]string{"a.txt": a, "b.txt": b}, vfs.DefaultOptions())
+	handler := srv.folderHandler()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/diff?"+query, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %q", query, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	want := "--- a/a.txt\n+++ b/b.txt\n@@ -1,4 +1,4 @@\n one\n-two\n+2\n three\n" +
+		"-<four> & \"five\"\n+<four> & \"five\"\tsix\u2028\n\\ No newline at end of file\n"
+	if got := get("a=a.txt&b=b.txt&format=text").Body.String(); got != want {
+		t.Fatalf("text diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	var resp struct {
+		A, B      string
+		Identical bool
+		Diff      string
+	}
+	body := get("a=a.txt&b=b.txt").Body.Bytes()
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode %q: %v", body, err)
+	}
+	if resp.A != "a.txt" || resp.B != "b.txt" || resp.Identical || resp.Diff != want {
+		t.Fatalf("JSON diff = %+v, want the text diff", resp)
+	}
+
+	if err := json.Unmarshal(get("a=a.txt&b=a.txt").Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Identical || resp.Diff != "" {
+		t.Fatalf("diff of a file with itself = %+v, want identical", resp)
+	}
+}
+
+func TestWriteJSONStringMatchesEncodingJSON(t *testing.T) {
+	text := "plain \"quoted\" back\\slash\n\r\t\x01\x1f <tag> & amp \u2028\u2029 é 日本 " + strings.Repeat("long ", 2000)
+	want, err := json.Marshal(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := writeJSONString(&got, []byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("writeJSONString differs from encoding/json:\n got %.200s\nwant %.200s", got.Bytes(), want)
+	}
+}
+
+func TestSplitLinesAliasesText(t *testing.T) {
+	text := []byte("secret one\nsecret two")
+	lines := splitLines(text)
+	clear(text)
+	for _, line := range lines {
+		if bytes.Contains(line, []byte("secret")) {
+			t.Fatalf("line %q survived clearing the text", line)
+		}
+	}
+}
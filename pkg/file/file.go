@@ -1,7 +1,6 @@
 package file
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
@@ -10,71 +9,192 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"mime"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
-	"github.com/oarkflow/previewer/assets"
 	"github.com/oarkflow/previewer/pkg/acl"
 	"github.com/oarkflow/previewer/pkg/vfs"
 
 	"github.com/gorilla/websocket"
 )
 
-// LogCallback is called when a security incident occurs
-type LogCallback func(data map[string]any)
+// LogCallback is called when a security incident occurs.
+// It is an alias of vfs.LogCallback so the same function works for both packages.
+type LogCallback = vfs.LogCallback
 
+// Default callback of previews started without a callback in their options
+// (see SetLogCallback)
 var (
 	logCallbackMu sync.RWMutex
-	logCallback   LogCallback = defaultLogCallback
+	logCallback   LogCallback
 )
 
-// defaultLogCallback is the default no-op callback
-var defaultLogCallback = func(data map[string]any) {
-	// Default: do nothing, just log to stdout
-	// Users can override with SetLogCallback
+var (
+	loggerMu sync.RWMutex
+	logger   *slog.Logger // nil means the default stderr logger
+)
+
+// defaultLogger writes to stderr, matching the standard log package.
+// Debug-level messages (per-file access) are only shown when verbose is set.
+func defaultLogger(verbose bool) *slog.Logger {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
 }
 
-// SetLogCallback sets a custom callback for security incident logging
+// SetLogger sets the logger used by the preview servers.
+// Passing nil restores the default stderr logger.
+func SetLogger(l *slog.Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	logger = l
+}
+
+// getLogger returns the package logger, or the default one at the given verbosity
+func getLogger(verbose bool) *slog.Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	if logger != nil {
+		return logger
+	}
+	return defaultLogger(verbose)
+}
+
+// SetLogCallback sets the callback of previews started afterwards without
+// vfs.Options.LogCallback or PreviewOptions.LogCallback. It is read once, at
+// start, and then gets the same incidents the option would. Passing nil
+// removes it.
+//
+// Deprecated: set vfs.Options.LogCallback or PreviewOptions.LogCallback.
 func SetLogCallback(fn LogCallback) {
 	logCallbackMu.Lock()
 	defer logCallbackMu.Unlock()
-	if fn != nil {
-		logCallback = fn
-	} else {
-		logCallback = defaultLogCallback
+	logCallback = fn
+}
+
+// incidentCallback returns the callback a preview hands its incidents and
+// its VFSs' incidents to: cb, or the SetLogCallback default when cb is nil,
+// behind emitIncident
+func incidentCallback(cb LogCallback) LogCallback {
+	if cb == nil {
+		logCallbackMu.RLock()
+		cb = logCallback
+		logCallbackMu.RUnlock()
+	}
+	if cb == nil {
+		return nil
 	}
+	return func(data map[string]any) { emitIncident(cb, data) }
 }
 
-// logSecurityIncident logs a security incident via callback
-func logSecurityIncident(incidentType, severity, message string, details map[string]any) {
-	logCallbackMu.RLock()
-	cb := logCallback
-	logCallbackMu.RUnlock()
+// emitIncident hands an incident payload, normalized, to cb (if any). A
+// panicking callback is recovered so it can't take down the server.
+func emitIncident(cb LogCallback, data map[string]any) {
+	if cb == nil {
+		return
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			getLogger(false).Error("security log callback panicked", "panic", rec)
+		}
+	}()
+	cb(normalizeIncident(data))
+}
 
-	data := map[string]any{
-		"timestamp":     time.Now().Unix(),
-		"incident_type": incidentType,
-		"severity":      severity, // "low", "medium", "high", "critical"
-		"message":       message,
-		"details":       details,
+// normalizeIncident returns a copy of data with every standard incident field
+// present and of the expected type, so callbacks can rely on the shape
+func normalizeIncident(data map[string]any) map[string]any {
+	out := make(map[string]any, len(data)+5)
+	for k, v := range data {
+		out[k] = v
+	}
+	if _, ok := out["timestamp"].(int64); !ok {
+		out["timestamp"] = time.Now().Unix()
+	}
+	if _, ok := out["incident_type"].(string); !ok {
+		out["incident_type"] = "unknown"
+	}
+	if _, ok := out["severity"].(string); !ok {
+		out["severity"] = "medium"
+	}
+	if _, ok := out["message"].(string); !ok {
+		out["message"] = ""
+	}
+	if _, ok := out["source"].(string); !ok {
+		out["source"] = vfs.IncidentSourceServer
 	}
+	switch v := out["details"].(type) {
+	case map[string]any:
+		if v == nil {
+			out["details"] = map[string]any{}
+		}
+	case nil:
+		out["details"] = map[string]any{}
+	default:
+		out["details"] = map[string]any{"value": v}
+	}
+	return out
+}
 
+// logSecurityIncident logs a security incident via callback
+func (s *previewServer) logSecurityIncident(incidentType, severity, message string, details map[string]any) {
 	// Call the callback
-	cb(data)
+	data := vfs.NewIncident(incidentType, severity, message, details)
+	if !s.options.IncidentFullPaths {
+		data = vfs.RedactIncident(s.options.RedactPaths, data)
+	}
+	emitIncident(s.options.LogCallback, data)
+	s.writeAudit(data)
 
-	// Also log to stdout
-	log.Printf("SECURITY INCIDENT [%s]: %s - %s", severity, incidentType, message)
+	// Also log to the server logger
+	level := slog.LevelWarn
+	if severity == "high" || severity == "critical" {
+		level = slog.LevelError
+	}
+	s.logger.Log(context.Background(), level, "security incident",
+		"severity", severity, "type", incidentType, "message", message)
+}
+
+// writeAudit writes an incident to Options.AuditLogWriter
+func (s *previewServer) writeAudit(data map[string]any) {
+	if err := vfs.WriteAudit(s.options.AuditLogWriter, vfs.AuditEventIncident, normalizeIncident(data)); err != nil {
+		s.logger.Warn("write audit log", "error", err)
+	}
+}
+
+const maxSearchResults = 100    // Upper bound for /api/search?limit=
+const maxFileParamLength = 1024 // Longest ?file= accepted by the folder file view
+const defaultMaxIncidentBodyBytes = 64 * 1024 // Largest /api/security-incident body unless Options.MaxIncidentBodyBytes is set
+const maxIncidentTypeLength = 64     // Longest incident_type accepted from the frontend
+const maxIncidentMessageLength = 1024 // Frontend incident messages are truncated to this
+
+// validIncidentType reports whether a frontend incident type is a short
+// snake_case identifier such as "devtools_opened"
+func validIncidentType(t string) bool {
+	if t == "" || len(t) > maxIncidentTypeLength {
+		return false
+	}
+	for _, c := range t {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
 }
 
 // FolderItem represents a file or folder in the folder structure
@@ -87,9 +207,13 @@ type FolderItem struct {
 	LastMod     int64             `json:"lastModified,omitempty"` // Unix milliseconds
 	Path        string            `json:"path"`
 	Children    []*FolderItem     `json:"children,omitempty"`
+	Hash        string            `json:"hash,omitempty"` // SHA-256 of a file's content; for folders, of its children's names and hashes
+	HasChildren bool              `json:"hasChildren,omitempty"` // Folder is not empty, even if Children was left out
+	ChildCount  int               `json:"childCount,omitempty"`  // Number of direct children of a folder
 	MimeType    string            `json:"mimeType,omitempty"`
 	IsSecure    bool              `json:"isSecure,omitempty"`
 	Permissions *acl.ItemPermissions  `json:"permissions,omitempty"`
+	Skipped     string            `json:"skipped,omitempty"` // Why the VFS left this file out (see vfs.SkipInfo), for the viewer to show it greyed out
 }
 
 // FolderMeta represents metadata about the folder
@@ -102,21 +226,25 @@ type FolderMeta struct {
 	TotalFolders int           `json:"totalFolders"`
 	LastMod      int64         `json:"lastModified,omitempty"`
 	IsSecure     bool          `json:"isSecure"`
+	RecentFiles  []*FolderItem `json:"recentFiles,omitempty"`  // Most recently modified files, newest first
+	LargestFiles []*FolderItem `json:"largestFiles,omitempty"` // Largest files, largest first
 }
 
 
-// Security configuration structures (same shape as previous implementation)
-type securityConfig struct {
+// SecurityConfig is injected into the preview page as
+// window.__SECURITY_CONFIG__ and tells the viewer which protections to apply
+type SecurityConfig struct {
 	NoCopy              bool             `json:"noCopy"`
 	NoDownload          bool             `json:"noDownload"`
 	ScreenshotResistant bool             `json:"screenshotResistant"`
 	Watermark           bool             `json:"watermark"`
-	WatermarkConfig     *watermarkConfig `json:"watermarkConfig,omitempty"`
+	WatermarkConfig     *WatermarkConfig `json:"watermarkConfig,omitempty"`
 	SessionTimeout      *int             `json:"sessionTimeout,omitempty"`
 	ActivityLogging     bool             `json:"activityLogging"`
 }
 
-type watermarkConfig struct {
+// WatermarkConfig describes the watermark drawn over the preview
+type WatermarkConfig struct {
 	Text     string  `json:"text"`
 	FontSize int     `json:"fontSize"`
 	Opacity  float64 `json:"opacity"`
@@ -125,31 +253,83 @@ type watermarkConfig struct {
 	Spacing  int     `json:"spacing"`
 }
 
+// SecurityPresetConfig returns the SecurityConfig of a preset:
+// vfs.SecurityPresetStandard allows copy and download with no watermark,
+// vfs.SecurityPresetStrict blocks both and adds screenshot resistance and a
+// CONFIDENTIAL watermark. The folder view defaults to standard and the
+// single-file preview to strict. SessionTimeout is left for the caller.
+func SecurityPresetConfig(preset string) (SecurityConfig, error) {
+	switch preset {
+	case vfs.SecurityPresetStandard:
+		return SecurityConfig{
+			NoCopy:              false,
+			NoDownload:          false,
+			ScreenshotResistant: false,
+			Watermark:           false,
+			ActivityLogging:     true,
+		}, nil
+	case vfs.SecurityPresetStrict:
+		return SecurityConfig{
+			NoCopy:              true,
+			NoDownload:          true,
+			ScreenshotResistant: true,
+			Watermark:           true,
+			WatermarkConfig: &WatermarkConfig{
+				Text:     "CONFIDENTIAL",
+				FontSize: 48,
+				Opacity:  0.15,
+				Rotation: -30,
+				Color:    "#888888",
+				Spacing:  200,
+			},
+			ActivityLogging: true,
+		}, nil
+	}
+	return SecurityConfig{}, fmt.Errorf("unknown security preset %q", preset)
+}
+
 type previewServer struct {
 	filePath       string
 	fileName       string
 	fileData       []byte
 	mimeType       string
-	securityConfig securityConfig
+	securityConfig SecurityConfig
 	indexHTML      []byte
+	indexTemplate  []byte // index.html before injection, read once at construction
+	dist           fs.FS  // Viewer assets, resolved once at construction
+	indexMu        sync.RWMutex // Guards indexHTML and folderMeta, which change on reload
 	cspNonce       string
 	upgrader       websocket.Upgrader
 	closeCh        chan struct{}
+	serveErr       chan error // Gets the error of a Serve that stopped on its own (see serve)
 	httpServer     *http.Server
 	folderPath     string // For folder preview mode
 	folderMeta     *FolderMeta // For folder preview mode
-	vfs            *vfs.VirtualFileSystem // Secure in-memory filesystem sandbox
-	wsConnections  int // Track active WebSocket connections
+	vfs            *vfs.VirtualFileSystem // Secure in-memory filesystem sandbox; the first folder's with mounts
+	mounts         []folderMount // Folders of a PreviewFolders preview (nil for a single folder)
+	wsMu           sync.Mutex
+	wsConns        map[*wsClient]struct{} // Open connections, guarded by wsMu
+	reloading      atomic.Bool // Set while browsers reconnect after a reload broadcast
+	shuttingDown   atomic.Bool // Set once waitForClose returns
+	logger         *slog.Logger
+	options        vfs.Options // Folder preview options
+	port           int // Listening port, used to validate WebSocket origins
+	remote         bool // Bound to a non-loopback interface (Options.ListenHost)
+	sessionToken   string // Preview session secret, handed to the browser as a cookie
+	idleTimer      *time.Timer // Fires after the session timeout without activity
+	warnTimer      *time.Timer // Fires shortly before idleTimer to warn connected browsers
+	connectTimer   *time.Timer // Fires if no WebSocket connects after startup
+	thumbs         thumbCache // path+hash+size -> JPEG thumbnail
 }
 
 func PreviewFile(filePath string) error {
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
-		log.Fatalf("resolve file: %v", err)
+		return fmt.Errorf("resolve file: %w", err)
 	}
 	f, err := os.Open(absPath)
 	if err != nil {
-		log.Fatalf("open file: %v", err)
+		return fmt.Errorf("open file: %w", err)
 	}
 	defer f.Close()
 	return Preview(f)
@@ -157,6 +337,8 @@ func PreviewFile(filePath string) error {
 
 // Preview reads the file from the provided reader and serves the preview UI until the user closes it.
 // If the reader implements Name() string (e.g. *os.File), the base name will be used in the UI and URL.
+// The file bytes and the page embedding them are zeroed on shutdown; the
+// copy already sent to the browser cannot be recalled.
 func Preview(r io.Reader) error {
 	if r == nil {
 		return errors.New("reader is nil")
@@ -166,6 +348,8 @@ func Preview(r io.Reader) error {
 	if err != nil {
 		return fmt.Errorf("read data: %w", err)
 	}
+	// The buffer is ours; don't leave the plaintext behind
+	defer clear(data)
 
 	name := "file"
 	if n, ok := r.(interface{ Name() string }); ok {
@@ -174,38 +358,80 @@ func Preview(r io.Reader) error {
 		}
 	}
 
-	srv, err := newPreviewServerFromBytes(name, data)
+	return previewBytes(name, data, PreviewOptions{})
+}
+
+// PreviewOptions configures PreviewBytes. The zero value gives the same
+// preview as Preview.
+type PreviewOptions struct {
+	MimeType        string          // Used instead of detecting the type from the name and content
+	Security        *SecurityConfig // Used as is instead of SecurityPreset
+	SecurityPreset  string          // vfs.SecurityPresetStrict (default) or vfs.SecurityPresetStandard; see SecurityPresetConfig
+	ShutdownTimeout time.Duration   // How long in-flight requests get to finish once the preview is closed (default 5s)
+	MaxInlineBytes  int64           // Largest file embedded into the page (default 2 MB); larger ones are fetched from /api/file
+	Silent          bool            // Discard the server's log output; incident callbacks still get everything
+	LogCallback     LogCallback     // Receives the preview's security incidents
+}
+
+// PreviewBytes serves data under the display name until the user closes the
+// preview. Only the first PreviewOptions is used. The server zeroes the page
+// it embedded data in on shutdown; data itself stays the caller's to clear.
+func PreviewBytes(name string, data []byte, opts ...PreviewOptions) error {
+	var o PreviewOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if name = filepath.Base(name); name == "." || name == "/" {
+		name = "file"
+	}
+	return previewBytes(name, data, o)
+}
+
+// previewBytes serves the single-file preview of data until the user closes
+// it
+func previewBytes(name string, data []byte, opts PreviewOptions) error {
+	srv, err := newPreviewServerFromBytes(name, data, opts)
 	if err != nil {
 		return fmt.Errorf("create preview server: %w", err)
 	}
+	defer srv.wipe()
+	srv.logger = getLogger(false)
+	if opts.Silent {
+		srv.logger = slog.New(slog.DiscardHandler)
+	}
 
-	listener, port := pickListener()
+	listener, port, err := pickListener("")
+	if err != nil {
+		return err
+	}
+	srv.port = port
+	srv.startTimers()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", srv.handleWS)
+	mux.HandleFunc("/healthz", srv.handleHealthz)
+	mux.HandleFunc("/readyz", srv.handleReadyz)
+	mux.HandleFunc(singleFileRoute, srv.handleSingleFile)
 	mux.Handle("/", srv.spaHandler())
 
-	httpServer := &http.Server{Handler: withLogging(mux)}
+	httpServer := &http.Server{Handler: srv.withLogging(mux)}
 	srv.httpServer = httpServer
 
-	go func() {
-		log.Printf("serving preview on http://localhost:%d (file: %s)", port, srv.fileName)
-		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
-		}
-	}()
+	srv.logger.Info("serving preview", "url", fmt.Sprintf("http://localhost:%d", port), "file", srv.fileName)
+	go srv.serve(httpServer, listener)
 
 	previewURL := fmt.Sprintf("http://localhost:%d/?file=%s", port, url.QueryEscape(srv.fileName))
-	if err := openBrowser(previewURL); err != nil {
-		log.Printf("open browser: %v", err)
-	}
+	srv.announceURL(previewURL, openBrowser(previewURL))
 
-	srv.waitForClose()
+	serveErr := srv.waitForClose()
 
-	ctx, cancel := context.WithTimeout(context.Background(), vfs.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(srv.options))
 	defer cancel()
 	_ = httpServer.Shutdown(ctx)
-	log.Println("server shutdown")
+	srv.logger.Info("server shutdown")
+	if serveErr != nil {
+		return fmt.Errorf("serve preview: %w", serveErr)
+	}
 	return nil
 }
 
@@ -217,8 +443,11 @@ func randomNonceBase64(n int) (string, error) {
 	return base64.RawStdEncoding.EncodeToString(b), nil
 }
 
-func newPreviewServerFromBytes(name string, fileData []byte) (*previewServer, error) {
-	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+func newPreviewServerFromBytes(name string, fileData []byte, opts PreviewOptions) (*previewServer, error) {
+	mimeType := opts.MimeType
+	if mimeType == "" {
+		mimeType = vfs.MimeTypeByExtension(name, nil)
+	}
 	if mimeType == "" {
 		// fallback to detection from content
 		if len(fileData) > 0 {
@@ -228,89 +457,88 @@ func newPreviewServerFromBytes(name string, fileData []byte) (*previewServer, er
 		}
 	}
 
-	// Read embedded index.html
-	dist, err := fs.Sub(assets.DistFS, "dist")
+	// Resolve the embedded viewer assets once
+	dist, indexBytes, err := loadAssets(vfs.Options{})
 	if err != nil {
-		return nil, fmt.Errorf("embed dist: %w", err)
+		return nil, err
+	}
+
+	// Maximum security configuration for secure preview, unless the caller
+	// picked another preset or config
+	sessionTimeout := int(vfs.DefaultSessionTimeout.Milliseconds())
+	preset := opts.SecurityPreset
+	if preset == "" {
+		preset = vfs.SecurityPresetStrict
 	}
-	indexBytes, err := fs.ReadFile(dist, "index.html")
+	secConfig, err := SecurityPresetConfig(preset)
 	if err != nil {
-		return nil, fmt.Errorf("read index.html: %w", err)
-	}
-
-	// Maximum security configuration for secure preview
-	sessionTimeout := 30 * 60 * 1000 // 30 minutes in milliseconds
-	secConfig := securityConfig{
-		NoCopy:              true,
-		NoDownload:          true,
-		ScreenshotResistant: true,
-		Watermark:           true,
-		WatermarkConfig: &watermarkConfig{
-			Text:     "CONFIDENTIAL",
-			FontSize: 48,
-			Opacity:  0.15,
-			Rotation: -30,
-			Color:    "#888888",
-			Spacing:  200,
-		},
-		SessionTimeout:  &sessionTimeout,
-		ActivityLogging: true,
+		return nil, err
+	}
+	if opts.Security != nil {
+		secConfig = *opts.Security
+	}
+	if secConfig.SessionTimeout == nil {
+		secConfig.SessionTimeout = &sessionTimeout
 	}
 
 	embeddedFile := map[string]interface{}{
 		"name":     name,
 		"size":     len(fileData),
 		"type":     mimeType,
-		"data":     base64.StdEncoding.EncodeToString(fileData),
+		"language": highlightLanguage(name, mimeType),
 		"embedded": true,
 	}
-	fileJSON, err := json.Marshal(embeddedFile)
-	if err != nil {
-		return nil, fmt.Errorf("marshal file data: %w", err)
-	}
-
-	securityJSON, err := json.Marshal(secConfig)
-	if err != nil {
-		return nil, fmt.Errorf("marshal security config: %w", err)
+	if int64(len(fileData)) > maxInlineBytes(opts.MaxInlineBytes) {
+		embeddedFile["src"] = singleFileRoute
+	} else {
+		embeddedFile["data"] = embedData(fileData)
 	}
 
 	nonce, err := randomNonceBase64(16)
 	if err != nil {
 		return nil, fmt.Errorf("nonce: %w", err)
 	}
+	sessionToken, err := randomNonceBase64(32)
+	if err != nil {
+		return nil, fmt.Errorf("session token: %w", err)
+	}
 
-	injectionScript := fmt.Sprintf(
-		`<script nonce="%s">window.__EMBEDDED_FILE__=%s;window.__SECURITY_CONFIG__=%s;</script>`,
-		nonce,
-		fileJSON,
-		securityJSON,
-	)
-	modifiedIndex := bytes.Replace(indexBytes, []byte("</head>"), []byte(injectionScript+"</head>"), 1)
+	modifiedIndex, err := injectIndex(indexBytes, nonce, embeddedFile, secConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	return &previewServer{
+	srv := &previewServer{
 		filePath:       "",
 		fileName:       name,
 		fileData:       fileData,
 		mimeType:       mimeType,
 		securityConfig: secConfig,
 		indexHTML:      modifiedIndex,
+		indexTemplate:  indexBytes,
+		dist:           dist,
 		cspNonce:       nonce,
-		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				host := strings.ToLower(r.Host)
-				return strings.HasPrefix(host, "localhost") || strings.HasPrefix(host, "127.0.0.1")
-			},
-		},
-		closeCh: make(chan struct{}),
-	}, nil
+		sessionToken:   sessionToken,
+		options:        vfs.Options{ShutdownTimeout: opts.ShutdownTimeout, LogCallback: incidentCallback(opts.LogCallback)},
+		closeCh:        make(chan struct{}),
+		serveErr:       make(chan error, 1),
+	}
+	srv.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     srv.checkOrigin,
+	}
+	return srv, nil
 }
 
 func (s *previewServer) spaHandler() http.Handler {
-	dist, err := fs.Sub(assets.DistFS, "dist")
-	if err != nil {
-		log.Fatalf("embed dist: %v", err)
+	dist := s.dist
+	if dist == nil {
+		// Missing viewer assets must not take the host process down
+		s.logger.Error("viewer assets unavailable")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Viewer assets unavailable", http.StatusInternalServerError)
+		})
 	}
 	fileServer := http.FileServer(http.FS(dist))
 
@@ -321,29 +549,63 @@ func (s *previewServer) spaHandler() http.Handler {
 			return
 		}
 
-		if r.URL.Path == "/" {			// Check if this is a file+folder request
+		if r.URL.Path == "/" {
+			// A file share link opens straight on its file
+			if scope, ok := shareScopeFrom(r.Context()); ok && scope.Path != "" {
+				html, nonce, err := s.generateFilePreviewHTML(r.Context(), scope.Path, s.requestIP(r))
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				if err != nil {
+					http.Error(w, "Shared file unavailable", http.StatusNotFound)
+					return
+				}
+				s.setHTMLHeaders(w, nonce)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(html)
+				return
+			}
+
+			// Check if this is a file+folder request
 			query := r.URL.Query()
 			fileParam := query.Get("file")
 			folderParam := query.Get("folder")
 
-			if fileParam != "" && folderParam != "" && s.folderPath != "" {
+			if query.Has("file") && folderParam != "" && s.folderPath != "" {
 				// User wants to view a specific file from the folder
-				html, err := s.generateFilePreviewHTML(fileParam)
+				if reason := s.rejectFileParam(fileParam); reason != "" {
+					s.logSecurityIncident("invalid_file_param", "medium", "Rejected file parameter", map[string]any{
+						"reason": reason,
+						"file":   truncate(fileParam, 256),
+						"length": len(fileParam),
+						"ip":     s.clientIP(r),
+					})
+					http.Error(w, "Invalid file parameter", http.StatusBadRequest)
+					return
+				}
+				html, nonce, err := s.generateFilePreviewHTML(r.Context(), fileParam, s.requestIP(r))
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				if errors.Is(err, vfs.ErrAccessDenied) {
+					mount, _, _ := s.resolve(fileParam)
+					s.denyFileAccess(w, mount.vfs, err, s.clientIP(r))
+					return
+				}
 				if err != nil {
 					http.Error(w, fmt.Sprintf("Failed to generate file preview: %v", err), http.StatusInternalServerError)
 					return
 				}
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.Header().Set("Cache-Control", "no-store")
+				s.setHTMLHeaders(w, nonce)
 				w.WriteHeader(http.StatusOK)
 				_, _ = w.Write(html)
 				return
 			}
 
-			// Normal folder or file preview			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Header().Set("Cache-Control", "no-store")
+			// Normal folder or file preview
+			s.setHTMLHeaders(w, s.cspNonce)
 			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write(s.indexHTML)
+			_, _ = w.Write(s.currentIndex())
 			return
 		}
 
@@ -361,39 +623,136 @@ func (s *previewServer) spaHandler() http.Handler {
 			return
 		}
 
-		// SPA fallback: serve modified index.html
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Header().Set("Cache-Control", "no-store")
+		// SPA fallback: serve modified index.html. It embeds the folder
+		// tree, which a file share link must not reveal.
+		if scope, ok := shareScopeFrom(r.Context()); ok && scope.Path != "" {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		s.setHTMLHeaders(w, s.cspNonce)
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(s.indexHTML)
+		_, _ = w.Write(s.currentIndex())
 	})
 }
 
+// rejectFileParam checks the ?file= parameter of a folder file view before it
+// reaches the VFS, returning the reason it was rejected or "" if it is fine
+func (s *previewServer) rejectFileParam(fileParam string) string {
+	switch {
+	case strings.TrimSpace(fileParam) == "":
+		return "empty"
+	case len(fileParam) > maxFileParamLength:
+		return "too long"
+	case !utf8.ValidString(fileParam):
+		return "invalid UTF-8"
+	}
+	if err := s.vfs.ValidatePath(fileParam); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// truncate shortens s to at most n bytes for logging
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "..."
+}
+
+// defaultContentSecurityPolicy only lets the bundled assets and the nonce'd
+// injection script run. {nonce} is replaced per response.
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'nonce-{nonce}'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob:; " +
+	"media-src 'self' data: blob:; " +
+	"font-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"worker-src 'self' blob:; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"frame-ancestors 'none'"
+
+// setHTMLHeaders writes the headers for an injected index page, including
+// a Content-Security-Policy bound to the page's script nonce
+func (s *previewServer) setHTMLHeaders(w http.ResponseWriter, nonce string) {
+	policy := s.options.ContentSecurityPolicy
+	if policy == "" {
+		policy = defaultContentSecurityPolicy
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", strings.ReplaceAll(policy, "{nonce}", nonce))
+	s.setSessionCookie(w)
+}
+
+// checkOrigin only accepts WebSocket handshakes from pages served by this
+// server: the Origin must be a loopback host on our own port, matching the
+// Host the request was sent to. Other local pages (e.g. localhost:9999) are
+// rejected. Options.AllowAnyWSOrigin disables the check for development.
+func (s *previewServer) checkOrigin(r *http.Request) bool {
+	if s.options.AllowAnyWSOrigin {
+		return true
+	}
+	return s.isOwnOrigin(r)
+}
+
+// isOwnOrigin reports whether the request's Origin is this server. A
+// remote-bound server accepts any host name it was reached by, as long as
+// Origin and Host agree; otherwise only loopback hosts are accepted.
+func (s *previewServer) isOwnOrigin(r *http.Request) bool {
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil || (origin.Scheme != "http" && origin.Scheme != "https") {
+		return false
+	}
+	if !strings.EqualFold(origin.Host, r.Host) {
+		return false
+	}
+	host, port, err := net.SplitHostPort(origin.Host)
+	if err != nil || port != strconv.Itoa(s.port) {
+		return false
+	}
+	if s.remote {
+		return true
+	}
+	host = strings.ToLower(host)
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
 func (s *previewServer) handleWS(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("ws upgrade: %v", err)
+		s.logger.Warn("ws upgrade", "error", err)
 		return
 	}
 
-	// Increment connection counter
-	s.wsConnections++
-	log.Printf("WebSocket connected (total connections: %d)", s.wsConnections)
+	s.connectTimer.Stop()
+	client := &wsClient{conn: conn}
+	connections := s.trackConn(client, true)
+	s.logger.Info("WebSocket connected", "connections", connections)
 
 	defer func() {
+		connections := s.trackConn(client, false)
 		conn.Close()
-		s.wsConnections--
-		log.Printf("WebSocket closed (remaining connections: %d)", s.wsConnections)
-
-		// Only shut down when ALL connections are closed
-		if s.wsConnections == 0 {
-			log.Println("All WebSocket connections closed, shutting down server")
+		s.logger.Info("WebSocket closed", "connections", connections)
+
+		// Only shut down when ALL connections are closed. After a reload
+		// broadcast the browsers reconnect, so wait for them instead.
+		if connections == 0 && s.reloading.Swap(false) {
+			s.logger.Info("waiting for browsers to reconnect after reload")
+			s.connectTimer.Reset(startupConnectTimeout(s.options))
+		} else if connections == 0 {
+			s.logger.Info("all WebSocket connections closed, shutting down server")
 			s.signalClose()
 		} else {
-			log.Printf("Keeping server alive (%d connections still active)", s.wsConnections)
+			s.logger.Info("keeping server alive", "connections", connections)
 		}
 	}()
 
+	conn.SetReadLimit(maxWSMessageBytes)
+	stopPing := s.keepAlive(client)
+	defer stopPing()
 	for {
 		mt, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -402,32 +761,72 @@ func (s *previewServer) handleWS(w http.ResponseWriter, r *http.Request) {
 		if mt != websocket.TextMessage {
 			continue
 		}
-		m := strings.ToLower(strings.TrimSpace(string(msg)))
-		if m == "ping" {
-			continue
-		}
-		if m == "close" || m == "closing" {
-			return
-		}
-		if strings.HasPrefix(m, "violation") || strings.Contains(m, "security_violation") {
-			log.Printf("security violation reported by client: %s", m)
+		s.touch()
+		client.extendDeadline(s.options)
+		if !s.handleWSMessage(r.Context(), client, msg) {
 			return
 		}
 	}
 }
 
-func (s *previewServer) waitForClose() {
+// waitForClose blocks until the preview is closed, a signal arrives or the
+// server fails, and returns the server's error in the last case
+func (s *previewServer) waitForClose() error {
 	sigCh := make(chan os.Signal, 1)
 	signalNotify(sigCh)
+	var err error
 	select {
 	case <-s.closeCh:
 	case <-sigCh:
+	case err = <-s.serveErr:
+	}
+	s.shuttingDown.Store(true)
+
+	// Tell the browsers before the server goes away
+	s.broadcast(wsEvent{Type: wsEventForceClose, Reason: "server shutting down"})
+	s.closeClients(websocket.CloseGoingAway, "shutdown")
+	return err
+}
+
+// serve runs httpServer on listener until it is shut down. If it stops on
+// its own, the error is logged and handed to waitForClose, which ends the
+// preview so that the caller's cleanup runs and the error is returned.
+func (s *previewServer) serve(httpServer *http.Server, listener net.Listener) {
+	defer s.wipeOnPanic()
+	if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		s.logger.Error("server error", "error", err)
+		s.serveErr <- err
 	}
 }
 
 // signalNotify is a small wrapper to allow easier unit testing of signal handling.
+// SIGTERM and SIGHUP stop the preview like Ctrl-C, so that kill and a closed
+// terminal still go through the cleanup.
 func signalNotify(ch chan os.Signal) {
-	signal.Notify(ch, os.Interrupt)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+}
+
+// wipe zeroes the plaintext the server holds: the embedded preview page and,
+// in folder mode, the VFS keys and data. The single-file data belongs to the
+// caller.
+func (s *previewServer) wipe() {
+	s.indexMu.Lock()
+	clear(s.indexHTML)
+	s.indexMu.Unlock()
+	s.thumbs.wipe()
+	for _, fs := range s.allVFS() {
+		fs.SecureCleanup()
+	}
+}
+
+// wipeOnPanic, deferred at the top of a server goroutine, wipes everything
+// including the previewed data before a panic takes the process down
+func (s *previewServer) wipeOnPanic() {
+	if rec := recover(); rec != nil {
+		s.wipe()
+		clear(s.fileData)
+		panic(rec)
+	}
 }
 
 func (s *previewServer) signalClose() {
@@ -437,45 +836,27 @@ func (s *previewServer) signalClose() {
 	}
 }
 
-func pickListener() (net.Listener, int) {
-	l, err := net.Listen("tcp", "127.0.0.1:0")
+// pickListener listens on a free port of host (127.0.0.1 if empty)
+func pickListener(host string) (net.Listener, int, error) {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
 	if err != nil {
-		log.Fatalf("listen: %v", err)
+		return nil, 0, fmt.Errorf("listen: %w", err)
 	}
 	addr := l.Addr().(*net.TCPAddr)
-	return l, addr.Port
+	return l, addr.Port, nil
 }
 
-func openBrowser(url string) error {
-	var cmd string
-	var args []string
-
-	switch runtime.GOOS {
-	case "darwin":
-		cmd = "open"
-		args = []string{url}
-	case "windows":
-		cmd = "rundll32"
-		args = []string{"url.dll,FileProtocolHandler", url}
-	default:
-		cmd = "xdg-open"
-		args = []string{url}
+// isLoopbackHost reports whether a listen host only accepts local
+// connections
+func isLoopbackHost(host string) bool {
+	if host == "" || strings.EqualFold(host, "localhost") {
+		return true
 	}
-
-	return execCommand(cmd, args...)
-}
-
-func execCommand(cmd string, args ...string) error {
-	c := exec.Command(cmd, args...)
-	return c.Start()
-}
-
-func withLogging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s (%s)", r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
-	})
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 // PreviewFolder serves a folder structure for preview in the browser
@@ -485,101 +866,212 @@ func PreviewFolder(folderPath string) error {
 
 // PreviewFolderWithOptions opens a folder preview with custom VFS options
 func PreviewFolderWithOptions(folderPath string, options vfs.Options) error {
-	absPath, err := filepath.Abs(folderPath)
-	if err != nil {
-		return fmt.Errorf("resolve folder path: %w", err)
+	return previewFolders([]string{folderPath}, options)
+}
+
+// PreviewFolders opens one preview listing several folders side by side,
+// e.g. two releases to compare. Each folder is loaded into its own VFS with
+// the same options and appears at the root of the tree under its base name
+// (repeated names are numbered: "dist", "dist-2").
+func PreviewFolders(paths []string, options vfs.Options) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no folders to preview")
 	}
+	return previewFolders(paths, options)
+}
 
-	// Check if path exists and is a directory
-	info, err := os.Stat(absPath)
-	if err != nil {
-		return fmt.Errorf("stat folder: %w", err)
+// previewFolders serves the folder preview of one folder, or of several
+// mounted side by side
+func previewFolders(paths []string, options vfs.Options) error {
+	absPaths := make([]string, len(paths))
+	for i, folderPath := range paths {
+		absPath, err := filepath.Abs(folderPath)
+		if err != nil {
+			return fmt.Errorf("resolve folder path: %w", err)
+		}
+
+		// Check if path exists and is a directory
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return fmt.Errorf("stat folder: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("path is not a directory: %s", absPath)
+		}
+		absPaths[i] = absPath
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("path is not a directory: %s", absPath)
+	var err error
+	if options.ShareTTL > 0 && len(options.ShareSecret) == 0 {
+		if options.ShareSecret, err = newShareSecret(); err != nil {
+			return err
+		}
+	}
+	if err := checkAuthOptions(options); err != nil {
+		return err
 	}
 
-	// Initialize secure in-memory VFS sandbox with options
-	log.Println("Loading folder into secure VFS sandbox...")
-	log.Printf("VFS Options: MaxFile=%dMB, MaxTotal=%dMB, Compress=%v, RateLimit=%d/min, AnomalyThreshold=%d, MLock=%v",
-		options.MaxFileSize/(1024*1024), options.MaxTotalSize/(1024*1024),
-		options.EnableCompression, options.MaxAccessPerFile, options.AnomalyThreshold, options.MLockMemory)
+	if options.Silent {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+	if options.Logger == nil {
+		options.Logger = getLogger(options.Verbose)
+	}
+	options.Logger = vfs.RedactingLogger(options.Logger, options.RedactPaths)
+	logger := options.Logger
 
-	fs, err := vfs.NewVirtualFileSystemWithOptions(absPath, options)
-	if err != nil {
-		return fmt.Errorf("create VFS: %w", err)
-	}
-
-	// Set up VFS callback to capture security incidents
-	vfs.SetLogCallback(func(data map[string]any) {
-		// Forward to default logger
-		logSecurityIncident(
-			data["incident_type"].(string),
-			data["severity"].(string),
-			data["message"].(string),
-			data["details"].(map[string]any),
-		)
-	})
+	// Initialize secure in-memory VFS sandbox with options
+	logger.Info("loading folder into secure VFS sandbox")
+	logger.Info("VFS options",
+		"max_file_mb", options.MaxFileSize/(1024*1024),
+		"max_total_mb", options.MaxTotalSize/(1024*1024),
+		"compress", options.EnableCompression,
+		"rate_limit_per_min", options.MaxAccessPerFile,
+		"anomaly_threshold", options.AnomalyThreshold,
+		"mlock", options.MLockMemory)
+
+	// One callback gets the incidents of the VFSs (which have already
+	// logged them) and of the server, normalized
+	options.LogCallback = incidentCallback(options.LogCallback)
+
+	var mounts []folderMount
+	names := mountNames(absPaths)
+	for i, absPath := range absPaths {
+		fs, err := vfs.NewVirtualFileSystemWithOptions(absPath, options)
+		if err != nil {
+			return fmt.Errorf("create VFS: %w", err)
+		}
+		// Wipe keys and data on every way out, runs after the server shutdown
+		defer fs.SecureCleanup()
 
-	fileCount, totalSize := fs.GetStats()
-	log.Printf("VFS loaded: %d files, %.2f MB", fileCount, float64(totalSize)/(1024*1024))
+		fileCount, totalSize := fs.GetStats()
+		logger.Info("VFS loaded", "folder", absPath, "files", fileCount, "total_size_mb", float64(totalSize)/(1024*1024))
+		mounts = append(mounts, folderMount{name: names[i], path: absPath, vfs: fs})
+	}
+	if len(mounts) == 1 {
+		mounts[0].name = ""
+	}
 
 	// Build folder structure
-	folderMeta, err := buildFolderStructure(absPath, "/", 0)
+	var folderMeta *FolderMeta
+	if len(mounts) == 1 {
+		folderMeta, err = buildFolderStructure(options, mounts[0].vfs, mounts[0].path)
+	} else {
+		folderMeta, err = buildMountedStructure(options, mounts)
+	}
 	if err != nil {
 		return fmt.Errorf("build folder structure: %w", err)
 	}
 
-	// Create a preview server for the folder
-	srv, err := newPreviewServerFromFolder(folderMeta)
+	// Create a preview server for the folder. Everything the handlers read
+	// is set here, before the listener starts serving; later changes (watch
+	// reloads) go through indexMu.
+	srv, err := newPreviewServerFromFolder(mounts[0].path, folderMeta, mounts[0].vfs, options)
 	if err != nil {
 		return fmt.Errorf("create folder preview server: %w", err)
 	}
-	srv.folderPath = absPath
-	srv.folderMeta = folderMeta
-	srv.vfs = fs // Attach VFS to server
-
-	listener, port := pickListener()
+	if len(mounts) > 1 {
+		srv.mounts = mounts
+	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", srv.handleWS)
-	mux.HandleFunc("/api/file", srv.handleFileFromFolder)
-	mux.HandleFunc("/api/security-incident", srv.handleSecurityIncident)
-	mux.Handle("/", srv.spaHandler())
+	listener, port, err := pickListener(options.ListenHost)
+	if err != nil {
+		return err
+	}
+	srv.port = port
+	if srv.remote {
+		logger.Warn("preview server is reachable from the network: anyone with the credentials or a share link can read the previewed files",
+			"listen", listener.Addr().String())
+	}
+	srv.startTimers()
+
+	if options.Watch {
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+		for _, m := range mounts {
+			go func() {
+				defer srv.wipeOnPanic()
+				_ = m.vfs.Watch(watchCtx, 0, srv.reloadFolder)
+			}()
+			logger.Info("watching folder for changes", "folder", m.path)
+		}
+	}
 
-	httpServer := &http.Server{Handler: withLogging(mux)}
+	httpServer := &http.Server{Handler: srv.folderHandler()}
 	srv.httpServer = httpServer
 
-	go func() {
-		log.Printf("serving folder preview on http://localhost:%d (folder: %s)", port, folderMeta.Name)
-		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
-		}
-	}()
+	logger.Info("serving folder preview", "url", fmt.Sprintf("http://localhost:%d", port), "folder", folderMeta.Name)
+	go srv.serve(httpServer, listener)
 
 	previewURL := fmt.Sprintf("http://localhost:%d/?folder=%s", port, url.QueryEscape(folderMeta.Name))
-	if err := openBrowser(previewURL); err != nil {
-		log.Printf("open browser: %v", err)
+	srv.announceURL(previewURL, openBrowser(previewURL))
+	if options.ShareTTL > 0 {
+		link, err := shareLink(options, port, time.Now().Add(options.ShareTTL))
+		if err != nil {
+			return err
+		}
+		logger.Info("share link", "url", link, "expires_in", options.ShareTTL)
 	}
 
-	srv.waitForClose()
+	serveErr := srv.waitForClose()
 
 	// Print security statistics before shutdown
-	stats := fs.GetSecurityStats()
-	log.Printf("VFS Security Stats: %+v", stats)
-
-	// Perform secure cleanup
-	defer fs.SecureCleanup()
+	for _, m := range mounts {
+		logger.Info("VFS security stats", "folder", m.path, "stats", m.vfs.GetSecurityStats())
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), vfs.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(srv.options))
 	defer cancel()
 	_ = httpServer.Shutdown(ctx)
-	log.Println("server shutdown")
+	srv.logger.Info("server shutdown")
+	if serveErr != nil {
+		return fmt.Errorf("serve folder preview: %w", serveErr)
+	}
 	return nil
 }
 
-// buildFolderStructure recursively builds the folder structure
-func buildFolderStructure(basePath, relativePath string, depth int) (*FolderMeta, error) {
+// folderHandler routes the requests of a folder preview, behind logging,
+// the IP filter, authentication and the remote session check
+func (srv *previewServer) folderHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.handleWS)
+	mux.HandleFunc("/healthz", srv.handleHealthz)
+	mux.HandleFunc("/readyz", srv.handleReadyz)
+	mux.HandleFunc("/api/file", srv.handleFileFromFolder)
+	mux.HandleFunc("/api/download", srv.handleDownload)
+	mux.HandleFunc("/api/thumbnail", srv.handleThumbnail)
+	mux.HandleFunc("/api/pdf-page", srv.handlePDFPage)
+	mux.HandleFunc("/api/search", srv.handleSearch)
+	mux.HandleFunc("/api/diff", srv.handleDiff)
+	mux.HandleFunc("/api/tree", srv.handleTree)
+	mux.HandleFunc("/api/file-stats", srv.handleFileStats)
+	mux.HandleFunc("/api/unlock", srv.handleUnlock)
+	mux.HandleFunc("/api/security-incident", srv.handleSecurityIncident)
+	mux.Handle("/", srv.spaHandler())
+	return srv.withLogging(srv.withIPFilter(srv.withAuth(srv.requireRemoteSession(mux))))
+}
+
+// buildFolderStructure builds the folder structure for the files loaded into
+// fs. Sizes, MIME types and hashes come from the VFS; files the VFS skipped
+// (too large, blocked, unreadable) are listed with the reason in Skipped.
+func buildFolderStructure(options vfs.Options, fs *vfs.VirtualFileSystem, basePath string) (*FolderMeta, error) {
+	files := make(map[string]vfs.FileInfo)
+	for _, info := range fs.ListFiles() {
+		files[filepath.ToSlash(info.Path)] = info
+	}
+	skipped := make(map[string]string)
+	for _, skip := range fs.SkippedFiles() {
+		skipped[filepath.ToSlash(skip.Path)] = skip.Reason
+	}
+	meta, err := walkFolder(options, fs, fs.PathFilter(), files, skipped, basePath, "/", 0)
+	if err != nil {
+		return nil, err
+	}
+	meta.RecentFiles, meta.LargestFiles = folderSummaries(meta.Items, options.FolderSummarySize)
+	return meta, nil
+}
+
+// walkFolder recursively builds the folder structure
+func walkFolder(options vfs.Options, fs *vfs.VirtualFileSystem, filter *vfs.PathFilter, files map[string]vfs.FileInfo, skipped map[string]string, basePath, relativePath string, depth int) (*FolderMeta, error) {
 	const maxDepth = 10 // Prevent infinite recursion
 	if depth > maxDepth {
 		return nil, fmt.Errorf("max folder depth exceeded")
@@ -597,17 +1089,44 @@ func buildFolderStructure(basePath, relativePath string, depth int) (*FolderMeta
 	itemID := 0
 
 	for _, entry := range entries {
-		// Skip hidden files and folders
-		if strings.HasPrefix(entry.Name(), ".") {
+		// Skip hidden files and folders unless asked to include them
+		if vfs.IsHidden(entry.Name()) && !options.IncludeHidden {
 			continue
 		}
 
 		entryPath := filepath.Join(fullPath, entry.Name())
 		entryRelPath := filepath.Join(relativePath, entry.Name())
 
+		// Skip entries matched by Exclude or .previewerignore
+		if filter.Excluded(entryRelPath, entry.IsDir()) {
+			continue
+		}
+
 		info, err := entry.Info()
 		if err != nil {
-			log.Printf("warning: skipping %s: %v", entry.Name(), err)
+			options.Logger.Warn("skipping entry", "path", entryRelPath, "error", err)
+			continue
+		}
+
+		// Only list files the VFS actually holds, and those it skipped
+		// with a reason
+		var vfile vfs.FileInfo
+		var reason string
+		if !entry.IsDir() {
+			key := strings.TrimPrefix(filepath.ToSlash(entryRelPath), "/")
+			var loaded bool
+			vfile, loaded = files[key]
+			if !loaded {
+				if reason, loaded = skipped[key]; !loaded {
+					continue
+				}
+			}
+		}
+
+		// Entries that deny reading are left out, folders with everything
+		// in them, as the served tree would drop them anyway
+		permissions := fs.Permissions(entryRelPath)
+		if !permissions.CanRead {
 			continue
 		}
 
@@ -618,11 +1137,7 @@ func buildFolderStructure(basePath, relativePath string, depth int) (*FolderMeta
 			Path:     entryRelPath,
 			LastMod:  info.ModTime().UnixMilli(),
 			IsSecure: false, // Can be customized based on folder permissions
-			Permissions: &acl.ItemPermissions{
-				CanRead:   true,
-				CanWrite:  false,
-				CanDelete: false,
-			},
+			Permissions: &permissions,
 		}
 
 		if entry.IsDir() {
@@ -631,25 +1146,34 @@ func buildFolderStructure(basePath, relativePath string, depth int) (*FolderMeta
 			totalFolders++
 
 			// Recursively build children
-			childMeta, err := buildFolderStructure(entryPath, entryRelPath, depth+1)
+			childMeta, err := walkFolder(options, fs, filter, files, skipped, entryPath, entryRelPath, depth+1)
 			if err != nil {
-				log.Printf("warning: skipping folder %s: %v", entry.Name(), err)
+				options.Logger.Warn("skipping folder", "path", entryRelPath, "error", err)
 				continue
 			}
 
 			item.Children = childMeta.Items
+			item.ChildCount = len(childMeta.Items)
+			item.HasChildren = item.ChildCount > 0
+			item.Hash = folderHash(childMeta.Items)
 			totalSize += childMeta.TotalSize
 			totalFiles += childMeta.TotalFiles
 			totalFolders += childMeta.TotalFolders
-		} else {
+		} else if reason != "" {
 			item.Type = "file"
 			item.Size = info.Size()
 			item.Extension = strings.TrimPrefix(filepath.Ext(entry.Name()), ".")
-			item.MimeType = mime.TypeByExtension(filepath.Ext(entry.Name()))
+			item.Skipped = reason
+		} else {
+			item.Type = "file"
+			item.Size = vfile.Size
+			item.Extension = strings.TrimPrefix(filepath.Ext(entry.Name()), ".")
+			item.MimeType = vfile.MimeType
 			if item.MimeType == "" {
 				item.MimeType = "application/octet-stream"
 			}
-			totalSize += info.Size()
+			item.Hash = vfile.Hash
+			totalSize += vfile.Size
 			totalFiles++
 		}
 
@@ -673,83 +1197,101 @@ func buildFolderStructure(basePath, relativePath string, depth int) (*FolderMeta
 }
 
 // newPreviewServerFromFolder creates a preview server for a folder structure
-func newPreviewServerFromFolder(folderMeta *FolderMeta) (*previewServer, error) {
-	// Read embedded index.html
-	dist, err := fs.Sub(assets.DistFS, "dist")
+// served from the given VFS
+func newPreviewServerFromFolder(folderPath string, folderMeta *FolderMeta, fs *vfs.VirtualFileSystem, options vfs.Options) (*previewServer, error) {
+	// Resolve the viewer assets (or the overrides from the options) once
+	dist, indexBytes, err := loadAssets(options)
 	if err != nil {
-		return nil, fmt.Errorf("embed dist: %w", err)
-	}
-	indexBytes, err := fs.ReadFile(dist, "index.html")
-	if err != nil {
-		return nil, fmt.Errorf("read index.html: %w", err)
+		return nil, err
 	}
 
-	// Security configuration for folder preview (no watermark for folder view)
-	sessionTimeout := 30 * 60 * 1000 // 30 minutes in milliseconds
-	secConfig := securityConfig{
-		NoCopy:              false, // Allow copy in folder view
-		NoDownload:          false, // Allow downloads from folder view
-		ScreenshotResistant: false, // No screenshot blocking for folder view
-		Watermark:           false, // No watermark for folder view itself
-		SessionTimeout:      &sessionTimeout,
-		ActivityLogging:     true,
+	// Security configuration for folder preview: the standard preset (no
+	// watermark, copy and download allowed) unless another one is selected
+	sessionTimeout := int(sessionTimeoutOrDefault(options.SessionTimeout).Milliseconds())
+	preset := options.SecurityPreset
+	if preset == "" {
+		preset = vfs.SecurityPresetStandard
 	}
-
-	// Create folder metadata for embedding
-	embeddedFolder := map[string]interface{}{
-		"name":       folderMeta.Name,
-		"size":       folderMeta.TotalSize,
-		"type":       "folder",
-		"extension":  "",
-		"isFolder":   true,
-		"folderData": folderMeta,
-		"embedded":   true,
+	secConfig, err := SecurityPresetConfig(preset)
+	if err != nil {
+		return nil, err
 	}
+	secConfig.SessionTimeout = &sessionTimeout
 
-	folderJSON, err := json.Marshal(embeddedFolder)
+	nonce, err := randomNonceBase64(16)
 	if err != nil {
-		return nil, fmt.Errorf("marshal folder data: %w", err)
+		return nil, fmt.Errorf("nonce: %w", err)
 	}
-
-	securityJSON, err := json.Marshal(secConfig)
+	sessionToken, err := randomNonceBase64(32)
 	if err != nil {
-		return nil, fmt.Errorf("marshal security config: %w", err)
+		return nil, fmt.Errorf("session token: %w", err)
 	}
 
-	nonce, err := randomNonceBase64(16)
+	modifiedIndex, err := injectFolderIndex(indexBytes, nonce, embeddedTree(folderMeta, options), secConfig)
 	if err != nil {
-		return nil, fmt.Errorf("nonce: %w", err)
+		return nil, err
 	}
 
-	injectionScript := fmt.Sprintf(
-		`<script nonce="%s">window.__EMBEDDED_FILE__=%s;window.__SECURITY_CONFIG__=%s;</script>`,
-		nonce,
-		folderJSON,
-		securityJSON,
-	)
-	modifiedIndex := bytes.Replace(indexBytes, []byte("</head>"), []byte(injectionScript+"</head>"), 1)
-
-	return &previewServer{
+	srv := &previewServer{
 		filePath:       "",
 		fileName:       folderMeta.Name,
 		fileData:       []byte{}, // No file data for folders
 		mimeType:       "folder",
 		securityConfig: secConfig,
 		indexHTML:      modifiedIndex,
+		indexTemplate:  indexBytes,
+		dist:           dist,
 		cspNonce:       nonce,
-		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				host := strings.ToLower(r.Host)
-				return strings.HasPrefix(host, "localhost") || strings.HasPrefix(host, "127.0.0.1")
-			},
-		},
-		closeCh: make(chan struct{}),
-	}, nil
+		sessionToken:   sessionToken,
+		folderPath:     folderPath,
+		folderMeta:     folderMeta,
+		vfs:            fs, // Secure VFS sandbox backing the folder
+		logger:         options.Logger,
+		options:        options,
+		remote:         !isLoopbackHost(options.ListenHost),
+		closeCh:        make(chan struct{}),
+		serveErr:       make(chan error, 1),
+	}
+	srv.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     srv.checkOrigin,
+	}
+	return srv, nil
 }
-// handleFileFromFolder serves a specific file from the folder structure using VFS
+
+// injectFolderIndex embeds the folder metadata and security config into
+// index.html
+func injectFolderIndex(indexBytes []byte, nonce string, folderMeta *FolderMeta, secConfig SecurityConfig) ([]byte, error) {
+	// Create folder metadata for embedding
+	embeddedFolder := map[string]interface{}{
+		"name":       folderMeta.Name,
+		"size":       folderMeta.TotalSize,
+		"type":       "folder",
+		"extension":  "",
+		"isFolder":   true,
+		"folderData": folderMeta,
+		"embedded":   true,
+	}
+
+	return injectIndex(indexBytes, nonce, embeddedFolder, secConfig)
+}
+
+// handleFileFromFolder serves a specific file from the folder structure using VFS.
+// Full bytes are refused when NoDownload is set; only HEAD metadata and the
+// preview page remain available.
 func (s *previewServer) handleFileFromFolder(w http.ResponseWriter, r *http.Request) {
+	s.serveFile(w, r, false)
+}
+
+// handleDownload serves a file from the folder as an attachment. It is
+// refused with 403 when the security config forbids downloads.
+func (s *previewServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	s.serveFile(w, r, true)
+}
+
+// serveFile implements /api/file and /api/download
+func (s *previewServer) serveFile(w http.ResponseWriter, r *http.Request, attachment bool) {
 	if s.vfs == nil {
 		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
 		return
@@ -762,70 +1304,319 @@ func (s *previewServer) handleFileFromFolder(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Extract client IP for tracking
-	clientIP := r.RemoteAddr
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		clientIP = forwarded
+	clientIP, remoteIP := s.clientIP(r), s.requestIP(r)
+	mount, mountPath, ok := s.resolve(filePath)
+	if !ok {
+		http.Error(w, "Access denied or file not found", http.StatusForbidden)
+		return
 	}
 
-	// Read file from secure VFS with IP tracking
-	vfile, err := s.vfs.ReadFileWithIP(filePath, clientIP)
+	// Metadata-only and revalidation requests: answer from the VFS index
+	// without decrypting
+	if r.Method == http.MethodHead || (s.options.AllowCaching && r.Header.Get("If-None-Match") != "") {
+		info, err := mount.vfs.StatWithIP(mountPath, remoteIP)
+		if err != nil {
+			s.logger.Warn("VFS stat error", "path", filePath, "ip", clientIP, "error", err)
+			s.denyFileAccess(w, mount.vfs, err, clientIP)
+			return
+		}
+		if s.options.AllowCaching && etagMatches(r.Header.Get("If-None-Match"), fileETag(info.Hash)) {
+			w.Header().Set("ETag", fileETag(info.Hash))
+			w.Header().Set("Cache-Control", "private, no-cache")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == http.MethodHead {
+			s.setFileHeaders(w, info)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if s.securityConfig.NoDownload {
+		reason := "downloads are disabled"
+		if !attachment {
+			reason = s.rejectPreviewRequest(r)
+		}
+		if reason != "" {
+			s.logSecurityIncident("download_blocked", "medium", "File request refused: "+reason, map[string]any{
+				"path": filePath,
+				"ip":   clientIP,
+			})
+			http.Error(w, "Downloads are disabled for this preview", http.StatusForbidden)
+			return
+		}
+		info, err := mount.vfs.StatWithIP(mountPath, remoteIP)
+		if err != nil {
+			s.logger.Warn("VFS stat error", "path", filePath, "ip", clientIP, "error", err)
+			s.denyFileAccess(w, mount.vfs, err, clientIP)
+			return
+		}
+		if s.rasterizedPDF(info) {
+			s.logSecurityIncident("download_blocked", "medium", "File request refused: PDF is only served as page images", map[string]any{
+				"path": filePath,
+				"ip":   clientIP,
+			})
+			http.Error(w, "This PDF is only available via /api/pdf-page", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Read file from secure VFS with IP tracking. Files stored gzip-compressed
+	// are passed through as-is to clients that accept gzip. Empty files
+	// decrypt to no data and are sent with Content-Length: 0.
+	var vfile *vfs.VirtualFile
+	var gzipped bool
+	var err error
+	if acceptsGzip(r) {
+		vfile, gzipped, err = mount.vfs.ReadFileGzip(r.Context(), mountPath, remoteIP)
+	} else {
+		vfile, err = mount.vfs.ReadFileContext(r.Context(), mountPath, remoteIP)
+	}
+	if errors.Is(err, context.Canceled) {
+		return // Client went away; nothing to send
+	}
 	if err != nil {
-		log.Printf("VFS read error for %s from %s: %v", filePath, clientIP, err)
-		http.Error(w, "Access denied or file not found", http.StatusForbidden)
+		s.logger.Warn("VFS read error", "path", filePath, "ip", clientIP, "error", err)
+		s.denyFileAccess(w, mount.vfs, err, clientIP)
 		return
 	}
 
+	// The plaintext is our own copy; wipe it once it has been written
+	defer clear(vfile.Data)
+	s.touch()
+
 	// Log access for security audit
-	log.Printf("VFS: serving file %s (size: %d bytes, hash: %s) to %s",
-		vfile.Path, vfile.Size, vfile.Hash[:8], clientIP)
+	s.logger.Debug("VFS: serving file",
+		"path", vfile.Path, "size", vfile.Size, "hash", shortHash(vfile.Hash), "ip", clientIP)
+
+	s.setFileHeaders(w, vfs.FileInfo{
+		Size:     vfile.Size,
+		MimeType: vfile.MimeType,
+		Hash:     vfile.Hash,
+		HMAC:     vfile.HMAC,
+	})
+	w.Header().Add("Vary", "Accept-Encoding")
+	if attachment {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": vfile.Name}))
+	}
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(vfile.Data)))
+	}
+	w.Write(vfile.Data)
+}
+
+// denyFileAccess answers a refused read or stat from fs with a 403, telling
+// banned clients when to retry
+func (s *previewServer) denyFileAccess(w http.ResponseWriter, fs *vfs.VirtualFileSystem, err error, clientIP string) {
+	if errors.Is(err, vfs.ErrIPBanned) {
+		if until, banned := fs.BanExpiry(clientIP); banned {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+		}
+	}
+	http.Error(w, "Access denied or file not found", http.StatusForbidden)
+}
 
-	w.Header().Set("Content-Type", vfile.MimeType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", vfile.Size))
-	w.Header().Set("X-File-Hash", vfile.Hash) // Integrity verification
-	w.Header().Set("X-File-HMAC", vfile.HMAC[:16]) // Partial HMAC for verification
+// clientIP returns the requester's IP as it may be logged, anonymized when
+// Options.AnonymizeIPs is set
+func (s *previewServer) clientIP(r *http.Request) string {
+	if s.vfs == nil {
+		return s.requestIP(r)
+	}
+	return s.vfs.AnonymizeIP(s.requestIP(r))
+}
+
+// requestIP returns the raw client address, which is what the VFS is given:
+// it applies the IP rules to it before anonymizing it for its records. It
+// is the connection address, without the port so every connection from a
+// client counts as one IP. X-Forwarded-For is set by the client and only
+// used with Options.TrustProxyHeaders, and then only its right-most entry,
+// the one the proxy added.
+func (s *previewServer) requestIP(r *http.Request) string {
+	if s.options.TrustProxyHeaders {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if last := strings.TrimSpace(entries[len(entries)-1]); last != "" {
+				return last
+			}
+		}
+	}
+	return remoteHost(r)
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// gzip;q=0 explicitly refuses the encoding
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(strings.TrimSpace(q), 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setFileHeaders writes the headers shared by GET and HEAD on /api/file.
+//
+// By default X-File-HMAC carries only the first 16 hex characters of the
+// HMAC: it is a short identifier for correlating responses, not a value a
+// client can verify. Set Options.FullHMACHeader to send the complete HMAC.
+func (s *previewServer) setFileHeaders(w http.ResponseWriter, info vfs.FileInfo) {
+	fileHMAC := info.HMAC
+	if !s.options.FullHMACHeader && len(fileHMAC) > 16 {
+		fileHMAC = fileHMAC[:16]
+	}
+
+	w.Header().Set("Content-Type", info.MimeType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+	w.Header().Set("X-File-Hash", info.Hash) // Integrity verification
+	w.Header().Set("X-File-HMAC", fileHMAC)
+	if s.options.AllowCaching {
+		// Cacheable, but the browser must revalidate with If-None-Match
+		w.Header().Set("ETag", fileETag(info.Hash))
+		w.Header().Set("Cache-Control", "private, no-cache")
+		return
+	}
 	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate") // Security: no caching
 	w.Header().Set("Pragma", "no-cache") // HTTP/1.0 compatibility
 	w.Header().Set("Expires", "0") // Proxies
-	w.Write(vfile.Data)
 }
 
-// handleSecurityIncident receives security incident reports from the frontend
-func (s *previewServer) handleSecurityIncident(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// shortHash abbreviates a hex digest for logs. It is safe for short or
+// empty strings, e.g. from a corrupted snapshot.
+func shortHash(h string) string {
+	if len(h) > 8 {
+		return h[:8]
+	}
+	return h
+}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+// fileETag builds a strong ETag from a file's SHA-256 content hash
+func fileETag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSearch answers full-text queries over the text files in the VFS
+func (s *previewServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requirePreviewSession(w, r) {
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > maxSearchResults {
+		limit = maxSearchResults
+	}
+
+	clientIP := s.clientIP(r)
+	results, err := s.search(r.Context(), query, s.requestIP(r), limit)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if errors.Is(err, vfs.ErrAccessDenied) {
+		s.logger.Warn("search refused", "ip", clientIP, "error", err)
+		s.denyFileAccess(w, s.vfs, err, clientIP)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("search failed", "error", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
 		return
 	}
+	if results == nil {
+		results = []vfs.SearchResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"query":   query,
+		"results": results,
+	})
+}
 
+// handleSecurityIncident receives security incident reports from the frontend
+// Only the preview page itself may report incidents: there are no CORS
+// headers, cross-site requests are refused and the body is bounded.
+func (s *previewServer) handleSecurityIncident(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Browsers send Origin on POST; a page elsewhere cannot fake it
+	origin := r.Header.Get("Origin")
+	fetchSite := r.Header.Get("Sec-Fetch-Site")
+	if (origin != "" && !s.isOwnOrigin(r)) || (fetchSite != "" && fetchSite != "same-origin") {
+		s.logger.Warn("refused cross-origin security incident report", "origin", truncate(origin, 256), "ip", s.clientIP(r))
+		http.Error(w, "Cross-origin requests are not allowed", http.StatusForbidden)
+		return
+	}
+
+	maxBody := s.options.MaxIncidentBodyBytes
+	if maxBody <= 0 {
+		maxBody = defaultMaxIncidentBodyBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
 	var incident map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&incident); err != nil {
-		log.Printf("Failed to decode security incident: %v", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		s.logger.Warn("failed to decode security incident", "error", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Extract incident details
+	// Extract incident details. The client is not trusted: the type and
+	// severity must be well-formed and the message is bounded.
 	incidentType, _ := incident["incident_type"].(string)
 	severity, _ := incident["severity"].(string)
 	message, _ := incident["message"].(string)
 	details, _ := incident["details"].(map[string]any)
+	if !validIncidentType(incidentType) {
+		http.Error(w, "Invalid incident_type", http.StatusBadRequest)
+		return
+	}
+	switch severity {
+	case "low", "medium", "high", "critical":
+	default:
+		http.Error(w, "Invalid severity", http.StatusBadRequest)
+		return
+	}
+	s.reportFrontendIncident(r.Context(), incidentType, severity, message, details)
 
-	// Log the incident
-	log.Printf("[SECURITY INCIDENT FROM FRONTEND] Type: %s, Severity: %s, Message: %s",
-		incidentType, severity, message)
-
-	// Forward to the callback system
-	logSecurityIncident(incidentType, severity, message, details)
-
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -833,88 +1624,125 @@ func (s *previewServer) handleSecurityIncident(w http.ResponseWriter, r *http.Re
 	})
 }
 
-// generateFilePreviewHTML generates HTML for previewing a specific file from the folder using VFS
-func (s *previewServer) generateFilePreviewHTML(filePath string) ([]byte, error) {
-	if s.vfs == nil {
-		return nil, fmt.Errorf("VFS not initialized")
+// reportFrontendIncident forwards an incident reported by the viewer to the
+// callback and the log, marked with source "frontend". The message is
+// truncated; type and severity must already be validated.
+func (s *previewServer) reportFrontendIncident(ctx context.Context, incidentType, severity, message string, details map[string]any) {
+	message = truncate(message, maxIncidentMessageLength)
+	data := vfs.NewIncident(incidentType, severity, message, details)
+	data["source"] = vfs.IncidentSourceFrontend
+	if !s.options.IncidentFullPaths {
+		data = vfs.RedactIncident(s.options.RedactPaths, data)
 	}
+	emitIncident(s.options.LogCallback, data)
+	s.writeAudit(data)
 
-	// Read file from secure VFS (includes path validation and access control)
-	vfile, err := s.vfs.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("VFS read error: %w", err)
+	level := slog.LevelWarn
+	if severity == "high" || severity == "critical" {
+		level = slog.LevelError
 	}
+	s.logger.Log(ctx, level, "security incident from frontend",
+		"type", incidentType, "severity", severity, "message", message, "source", vfs.IncidentSourceFrontend)
+}
 
-	// Log access for security audit
-	log.Printf("VFS: generating preview for %s (size: %d bytes, hash: %s)",
-		vfile.Path, vfile.Size, vfile.Hash[:8])
+// generateFilePreviewHTML generates HTML for previewing a specific file from the folder using VFS.
+// It returns the page and the CSP nonce used for its inline script. Office
+// documents are embedded as PDF when Options.Converter is set. Files over
+// Options.MaxInlineBytes are embedded without their bytes, as a "src" the
+// viewer fetches, so they are not decrypted for the page at all. The file is
+// read for remoteIP (s.requestIP), with the same IP rules, bans, locks, rate
+// limits and tracking as /api/file.
+func (s *previewServer) generateFilePreviewHTML(ctx context.Context, filePath, remoteIP string) ([]byte, string, error) {
+	if s.vfs == nil {
+		return nil, "", fmt.Errorf("VFS not initialized")
+	}
 
-	// Encode file data as base64
-	encodedData := base64.StdEncoding.EncodeToString(vfile.Data)
+	mount, mountPath, ok := s.resolve(filePath)
+	if !ok {
+		return nil, "", fmt.Errorf("VFS read error: file not found: %s", filePath)
+	}
 
-	// Get embedded index.html
-	dist, err := fs.Sub(assets.DistFS, "dist")
+	// Oversized or non-previewable files are not embedded: decoding them
+	// into the page would freeze the tab. Links on the page use the path
+	// the viewer knows the file by.
+	info, err := mount.vfs.StatWithIP(mountPath, remoteIP)
 	if err != nil {
-		return nil, fmt.Errorf("embed dist: %w", err)
+		return nil, "", fmt.Errorf("VFS read error: %w", err)
 	}
-	indexBytes, err := fs.ReadFile(dist, "index.html")
-	if err != nil {
-		return nil, fmt.Errorf("read index.html: %w", err)
-	}
-
-	// Security configuration for file preview with max security
-	sessionTimeout := 30 * 60 * 1000 // 30 minutes in milliseconds
-	secConfig := securityConfig{
-		NoCopy:              true,
-		NoDownload:          true,
-		ScreenshotResistant: true,
-		Watermark:           true,
-		WatermarkConfig: &watermarkConfig{
-			Text:     "CONFIDENTIAL",
-			FontSize: 48,
-			Opacity:  0.1,
-			Rotation: -45,
-			Color:    "#000000",
-			Spacing:  200,
-		},
-		SessionTimeout:  &sessionTimeout,
-		ActivityLogging: true,
-	}
-
-	// Create file metadata for embedding
-	embeddedFile := map[string]interface{}{
-		"name":      vfile.Name,
-		"size":      vfile.Size,
-		"type":      vfile.MimeType,
-		"extension": strings.TrimPrefix(filepath.Ext(vfile.Name), "."),
-		"data":      encodedData,
-		"embedded":  true,
-		"isFolder":  false,
-		"hash":      vfile.Hash, // Include hash for integrity verification
+	info.Path = mount.fullPath(info.Path)
+	if ok, reason := s.inlinePreviewable(info); !ok {
+		return s.notInlineResult(info, reason)
+	}
+	// The PDF itself never goes into the page when it's shown as images
+	if s.rasterizedPDF(info) {
+		return s.pdfPagesPage(info)
+	}
+	if !s.convertible(info) && info.Size > maxInlineBytes(s.options.MaxInlineBytes) {
+		return s.fileSourcePage(info)
 	}
 
-	fileJSON, err := json.Marshal(embeddedFile)
+	// Read file from secure VFS (includes path validation and access control)
+	vfile, err := mount.vfs.ReadFileContext(ctx, mountPath, remoteIP)
 	if err != nil {
-		return nil, fmt.Errorf("marshal file data: %w", err)
+		return nil, "", fmt.Errorf("VFS read error: %w", err)
+	}
+	defer clear(vfile.Data)
+	if s.convertible(info) {
+		converted, err := s.convertToPDF(ctx, vfile)
+		if err != nil {
+			s.logger.Warn("document conversion failed", "path", vfile.Path, "error", err)
+			return s.notInlineResult(info, "it could not be converted to PDF")
+		}
+		defer clear(converted.Data)
+		vfile = converted
 	}
 
-	securityJSON, err := json.Marshal(secConfig)
-	if err != nil {
-		return nil, fmt.Errorf("marshal security config: %w", err)
+	// Log access for security audit
+	s.logger.Debug("VFS: generating preview",
+		"path", vfile.Path, "size", vfile.Size, "hash", shortHash(vfile.Hash))
+
+	embeddedFile := embeddedFileMeta(vfile.Name, vfile.Size, vfile.MimeType, vfile.Hash)
+	embeddedFile["data"] = embedData(vfile.Data)
+	return s.filePreviewPage(embeddedFile)
+}
+
+// fileSourcePage is the preview page of a file the viewer fetches from
+// /api/file rather than finding embedded
+func (s *previewServer) fileSourcePage(info vfs.FileInfo) ([]byte, string, error) {
+	s.logger.Debug("VFS: generating preview by reference",
+		"path", info.Path, "size", info.Size, "hash", shortHash(info.Hash))
+	embeddedFile := embeddedFileMeta(info.Name, info.Size, info.MimeType, info.Hash)
+	embeddedFile["src"] = "/api/file?path=" + url.QueryEscape(info.Path)
+	return s.filePreviewPage(embeddedFile)
+}
+
+// embeddedFileMeta is the window.__EMBEDDED_FILE__ of a folder file, to
+// which the caller adds its "data" or "src"
+func embeddedFileMeta(name string, size int64, mimeType, hash string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      name,
+		"size":      size,
+		"type":      mimeType,
+		"extension": strings.TrimPrefix(filepath.Ext(name), "."),
+		"language":  highlightLanguage(name, mimeType),
+		"embedded":  true,
+		"isFolder":  false,
+		"hash":      hash, // Include hash for integrity verification
 	}
+}
 
+// filePreviewPage injects a folder file into the viewer page under a fresh
+// nonce. Files opened from the folder get the same security settings the
+// server was configured with, so the folder's intent carries over.
+func (s *previewServer) filePreviewPage(embeddedFile map[string]interface{}) ([]byte, string, error) {
 	nonce, err := randomNonceBase64(16)
 	if err != nil {
-		return nil, fmt.Errorf("nonce: %w", err)
+		return nil, "", fmt.Errorf("nonce: %w", err)
 	}
 
-	injectionScript := fmt.Sprintf(
-		`<script nonce="%s">window.__EMBEDDED_FILE__=%s;window.__SECURITY_CONFIG__=%s;</script>`,
-		nonce,
-		fileJSON,
-		securityJSON,
-	)
-	modifiedIndex := bytes.Replace(indexBytes, []byte("</head>"), []byte(injectionScript+"</head>"), 1)
-
-	return modifiedIndex, nil
+	modifiedIndex, err := injectIndex(s.indexTemplate, nonce, embeddedFile, s.securityConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return modifiedIndex, nonce, nil
 }
@@ -0,0 +1,47 @@
+package file
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestEmptyFileRoundTrip(t *testing.T) {
+	options := vfs.DefaultOptions()
+	options.EnableCompression = true
+	srv := newTestFolderServer(t, map[string]string{"empty.txt": ""}, options)
+
+	vfile, err := srv.vfs.ReadFileWithIP("empty.txt", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("ReadFileWithIP: %v", err)
+	}
+	if vfile.Size != 0 || len(vfile.Data) != 0 || vfile.Hash == "" {
+		t.Fatalf("ReadFileWithIP = size %d, %d bytes, hash %q; want an empty file with a hash", vfile.Size, len(vfile.Data), vfile.Hash)
+	}
+
+	handler := srv.folderHandler()
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/file?path=empty.txt", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Accept-Encoding %q: status %d, body %q", acceptEncoding, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Length"); got != "0" {
+			t.Errorf("Accept-Encoding %q: Content-Length = %q, want 0", acceptEncoding, got)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want none", acceptEncoding, got)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("Accept-Encoding %q: body = %q, want empty", acceptEncoding, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package file
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleHealthz reports that the server is up: it answers once the listener
+// is serving and, in folder mode, the VFS has been loaded. No session or
+// security checks apply.
+func (s *previewServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if s.folderPath != "" && s.vfs == nil {
+		writeHealth(w, http.StatusServiceUnavailable, "loading")
+		return
+	}
+	writeHealth(w, http.StatusOK, "ok")
+}
+
+// handleReadyz additionally reports not ready once shutdown has begun or the
+// VFS has been securely cleaned up
+func (s *previewServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case s.folderPath != "" && s.vfs == nil:
+		writeHealth(w, http.StatusServiceUnavailable, "loading")
+	case s.shuttingDown.Load():
+		writeHealth(w, http.StatusServiceUnavailable, "shutting down")
+	case s.vfs != nil && s.vfs.IsClosed():
+		writeHealth(w, http.StatusServiceUnavailable, "cleaned up")
+	default:
+		writeHealth(w, http.StatusOK, "ready")
+	}
+}
+
+func writeHealth(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
@@ -0,0 +1,61 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestIncidentCallbackNilDetails(t *testing.T) {
+	var got []map[string]any
+	cb := incidentCallback(func(data map[string]any) { got = append(got, data) })
+
+	cb(map[string]any{"incident_type": "test", "details": nil})
+	cb(vfs.NewIncident("test", "low", "typed nil details", nil))
+
+	if len(got) != 2 {
+		t.Fatalf("callback got %d incidents, want 2", len(got))
+	}
+	for i, data := range got {
+		details, ok := data["details"].(map[string]any)
+		if !ok || details == nil {
+			t.Errorf("incident %d: details = %#v, want an empty map", i, data["details"])
+		}
+	}
+}
+
+func TestIncidentCallbackMalformedIncident(t *testing.T) {
+	var got map[string]any
+	cb := incidentCallback(func(data map[string]any) { got = data })
+
+	// Wrong types everywhere and no message, source or timestamp
+	cb(map[string]any{
+		"incident_type": 42,
+		"severity":      []string{"high"},
+		"details":       "not a map",
+	})
+
+	if got == nil {
+		t.Fatal("callback not called")
+	}
+	for field, want := range map[string]any{
+		"incident_type": "unknown",
+		"severity":      "medium",
+		"message":       "",
+		"source":        vfs.IncidentSourceServer,
+	} {
+		if got[field] != want {
+			t.Errorf("%s = %#v, want %#v", field, got[field], want)
+		}
+	}
+	if _, ok := got["timestamp"].(int64); !ok {
+		t.Errorf("timestamp = %#v, want an int64", got["timestamp"])
+	}
+	details, ok := got["details"].(map[string]any)
+	if !ok || details["value"] != "not a map" {
+		t.Errorf("details = %#v, want the string under \"value\"", got["details"])
+	}
+
+	// A panicking callback is recovered
+	incidentCallback(func(map[string]any) { panic("callback bug") })(nil)
+}
@@ -0,0 +1,134 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+const defaultMaxInlinePreviewBytes = 20 * 1024 * 1024
+
+// defaultMaxInlineBytes is the largest file whose bytes are embedded into
+// the preview page. Larger files are embedded as metadata with a "src" the
+// viewer fetches, so the page stays small and the bytes are streamed.
+const defaultMaxInlineBytes = 2 * 1024 * 1024
+
+// singleFileRoute serves the content of a single-file preview
+const singleFileRoute = "/api/file"
+
+// maxInlineBytes applies the default to an unset inline threshold
+func maxInlineBytes(n int64) int64 {
+	if n <= 0 {
+		return defaultMaxInlineBytes
+	}
+	return n
+}
+
+// handleSingleFile serves the previewed bytes of a single-file preview, for
+// files too large to embed. Range requests are supported so media can be
+// streamed. With NoDownload (the single-file default) only the preview UI's
+// own requests are answered.
+func (s *previewServer) handleSingleFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requirePreviewSession(w, r) {
+		return
+	}
+	s.touch()
+	w.Header().Set("Content-Type", s.mimeType)
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, s.fileName, time.Time{}, bytes.NewReader(s.fileData))
+}
+
+// inlinePreviewTypes are the MIME types (or prefixes ending in "/") the
+// viewer can render from an embedded copy
+var inlinePreviewTypes = []string{
+	"text/",
+	"image/",
+	"audio/",
+	"video/",
+	"application/pdf",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+}
+
+// inlinePreviewable reports whether a file may be embedded into the preview
+// page, returning the reason if not
+func (s *previewServer) inlinePreviewable(info vfs.FileInfo) (bool, string) {
+	maxBytes := s.options.MaxInlinePreviewBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxInlinePreviewBytes
+	}
+	if info.Size > maxBytes {
+		return false, fmt.Sprintf("it is larger than the %d MB inline preview limit", maxBytes/(1024*1024))
+	}
+	if s.convertible(info) {
+		return true, ""
+	}
+	for _, t := range inlinePreviewTypes {
+		if strings.HasPrefix(info.MimeType, t) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("files of type %s cannot be previewed", info.MimeType)
+}
+
+var notInlineTemplate = template.Must(template.New("not-inline").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style nonce="{{.Nonce}}">body{font-family:system-ui,sans-serif;max-width:40rem;margin:4rem auto;padding:0 1rem;color:#222}code{background:#f3f3f3;padding:.1rem .3rem}</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>This file is not shown inline because {{.Reason}}.</p>
+{{if .Download}}<p><a href="{{.Download}}">Download the file</a> or stream it from <code>{{.Stream}}</code>.</p>
+{{else}}<p>Downloads are disabled for this preview.</p>
+{{end}}</body>
+</html>
+`))
+
+// notInlinePage renders the page served instead of an embedded preview
+func (s *previewServer) notInlinePage(info vfs.FileInfo, reason, nonce string) ([]byte, error) {
+	data := struct {
+		Name, Reason, Nonce, Download, Stream string
+	}{
+		Name:   info.Name,
+		Reason: reason,
+		Nonce:  nonce,
+	}
+	if !s.securityConfig.NoDownload {
+		q := url.QueryEscape(info.Path)
+		data.Download = "/api/download?path=" + q
+		data.Stream = "/api/file?path=" + q
+	}
+	var buf bytes.Buffer
+	if err := notInlineTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// notInlineResult is notInlinePage with a fresh nonce, in the shape
+// generateFilePreviewHTML returns
+func (s *previewServer) notInlineResult(info vfs.FileInfo, reason string) ([]byte, string, error) {
+	nonce, err := randomNonceBase64(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("nonce: %w", err)
+	}
+	page, err := s.notInlinePage(info, reason, nonce)
+	if err != nil {
+		return nil, "", fmt.Errorf("render page: %w", err)
+	}
+	return page, nonce, nil
+}
@@ -0,0 +1,41 @@
+package file
+
+import (
+	"net"
+	"net/http"
+)
+
+// withIPFilter refuses requests from connections outside Options.AllowedIPs
+// and Options.BlockedIPs with a 403, before they reach any handler that
+// serves file content. The address is the one requestIP returns: the
+// connection's, unless Options.TrustProxyHeaders is set. Health checks stay
+// open.
+func (s *previewServer) withIPFilter(next http.Handler) http.Handler {
+	if s.vfs == nil || !s.vfs.HasIPRules() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" && r.URL.Path != "/readyz" && !s.vfs.IPAllowed(s.requestIP(r)) {
+			s.logSecurityIncident("ip_blocked", "high", "Request from a blocked IP refused", map[string]any{
+				"route": r.URL.Path,
+				"ip":    s.clientIP(r),
+			})
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// remoteHost returns the IP of the connection a request came in on. It is
+// never empty, so no request reaches the VFS as an anonymous caller.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if host == "" {
+		return "unknown"
+	}
+	return host
+}
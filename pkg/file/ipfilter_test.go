@@ -0,0 +1,54 @@
+package file
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestForwardedForCannotDodgeBlockedIPs(t *testing.T) {
+	for _, anonymize := range []bool{false, true} {
+		options := vfs.DefaultOptions()
+		options.BlockedIPs = []string{"192.0.2.1"}
+		options.AnonymizeIPs = anonymize
+		srv := newTestFolderServer(t, map[string]string{"a.txt": "plaintext"}, options)
+		handler := srv.folderHandler()
+
+		for _, forwarded := range []string{"", "10.0.0.1", "anon-x", "10.0.0.1, 10.0.0.2"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/file?path=a.txt", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if forwarded != "" {
+				req.Header.Set("X-Forwarded-For", forwarded)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("anonymize=%v X-Forwarded-For %q: status %d, want 403", anonymize, forwarded, rec.Code)
+			}
+		}
+	}
+}
+
+func TestTrustedProxyUsesRightMostForwardedFor(t *testing.T) {
+	options := vfs.DefaultOptions()
+	options.BlockedIPs = []string{"192.0.2.1"}
+	options.TrustProxyHeaders = true
+	srv := newTestFolderServer(t, map[string]string{"a.txt": "plaintext"}, options)
+	handler := srv.folderHandler()
+
+	for forwarded, want := range map[string]int{
+		"10.0.0.9, 192.0.2.1": http.StatusForbidden,
+		"192.0.2.1, 10.0.0.9": http.StatusOK, // The client wrote the first entry
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/file?path=a.txt", nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("X-Forwarded-For %q: status %d, want %d", forwarded, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package file
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// highlightLanguages maps file extensions to the language names the viewer's
+// syntax highlighter understands
+var highlightLanguages = map[string]string{
+	".go":         "go",
+	".rs":         "rust",
+	".ts":         "typescript",
+	".tsx":        "typescript",
+	".js":         "javascript",
+	".jsx":        "javascript",
+	".mjs":        "javascript",
+	".cjs":        "javascript",
+	".py":         "python",
+	".rb":         "ruby",
+	".php":        "php",
+	".java":       "java",
+	".kt":         "kotlin",
+	".swift":      "swift",
+	".c":          "c",
+	".h":          "c",
+	".cpp":        "cpp",
+	".cc":         "cpp",
+	".hpp":        "cpp",
+	".cs":         "csharp",
+	".sh":         "bash",
+	".bash":       "bash",
+	".zsh":        "bash",
+	".sql":        "sql",
+	".md":         "markdown",
+	".markdown":   "markdown",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".toml":       "toml",
+	".ini":        "ini",
+	".json":       "json",
+	".xml":        "xml",
+	".html":       "html",
+	".htm":        "html",
+	".css":        "css",
+	".scss":       "scss",
+	".less":       "less",
+	".proto":      "protobuf",
+	".graphql":    "graphql",
+	".dockerfile": "dockerfile",
+	".vue":        "vue",
+	".svelte":     "svelte",
+}
+
+// highlightMimeLanguages is the fallback for files whose extension is not
+// in highlightLanguages, e.g. stdin previews with only a MIME type
+var highlightMimeLanguages = map[string]string{
+	"text/x-go":          "go",
+	"text/x-rust":        "rust",
+	"text/x-typescript":  "typescript",
+	"text/javascript":    "javascript",
+	"text/x-python":      "python",
+	"text/x-ruby":        "ruby",
+	"text/x-php":         "php",
+	"text/x-java":        "java",
+	"text/x-kotlin":      "kotlin",
+	"text/x-swift":       "swift",
+	"text/x-c":           "c",
+	"text/x-c++":         "cpp",
+	"text/x-csharp":      "csharp",
+	"text/x-shellscript": "bash",
+	"text/x-sql":         "sql",
+	"text/markdown":      "markdown",
+	"text/yaml":          "yaml",
+	"text/x-toml":        "toml",
+	"application/json":   "json",
+	"text/xml":           "xml",
+	"application/xml":    "xml",
+	"text/html":          "html",
+	"text/css":           "css",
+	"text/x-scss":        "scss",
+	"text/x-less":        "less",
+}
+
+// highlightLanguage returns the syntax highlighting hint for a file, or ""
+// when it is not code. Dockerfile and Makefile are matched by name.
+func highlightLanguage(name, mimeType string) string {
+	base := strings.ToLower(filepath.Base(name))
+	switch base {
+	case "dockerfile":
+		return "dockerfile"
+	case "makefile", "gnumakefile":
+		return "makefile"
+	}
+	if lang, ok := highlightLanguages[filepath.Ext(base)]; ok {
+		return lang
+	}
+	essence, _, _ := strings.Cut(mimeType, ";")
+	return highlightMimeLanguages[strings.ToLower(strings.TrimSpace(essence))]
+}
@@ -0,0 +1,164 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// Multi-folder previews
+//
+// PreviewFolders loads every folder into its own VFS and lists them as
+// sibling folders at the root of one tree, each under its base name. Paths
+// the viewer sends ("/release-1.2/bin/tool") start with that name, which
+// picks the VFS; the rest is the path inside it. Each VFS keeps its own
+// access records, locks and bans.
+
+// folderMount is one folder of a multi-folder preview
+type folderMount struct {
+	name string // First path segment the folder is listed under; "" for a single folder
+	path string // Absolute source folder
+	vfs  *vfs.VirtualFileSystem
+}
+
+// fullPath returns the path the viewer knows a mount's file by
+func (m folderMount) fullPath(rel string) string {
+	if m.name == "" {
+		return rel
+	}
+	return path.Join(m.name, filepath.ToSlash(rel))
+}
+
+// resolve picks the VFS a requested path belongs to and returns the path
+// inside it. It reports false when the path names no mounted folder.
+func (s *previewServer) resolve(p string) (folderMount, string, bool) {
+	if len(s.mounts) == 0 {
+		return folderMount{path: s.folderPath, vfs: s.vfs}, p, true
+	}
+	name, rest, _ := strings.Cut(strings.TrimLeft(filepath.ToSlash(p), "/"), "/")
+	for _, m := range s.mounts {
+		if m.name == name {
+			return m, rest, true
+		}
+	}
+	return folderMount{}, "", false
+}
+
+// allVFS returns the VFS of every mounted folder, or the only one
+func (s *previewServer) allVFS() []*vfs.VirtualFileSystem {
+	if len(s.mounts) == 0 {
+		if s.vfs == nil {
+			return nil
+		}
+		return []*vfs.VirtualFileSystem{s.vfs}
+	}
+	all := make([]*vfs.VirtualFileSystem, len(s.mounts))
+	for i, m := range s.mounts {
+		all[i] = m.vfs
+	}
+	return all
+}
+
+// mountNames names each folder after its base name, numbering repeats
+// ("dist", "dist-2")
+func mountNames(paths []string) []string {
+	names := make([]string, len(paths))
+	seen := make(map[string]int)
+	for i, p := range paths {
+		name := filepath.Base(p)
+		if name == "/" || name == "." || name == string(filepath.Separator) {
+			name = "root"
+		}
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name += "-" + strconv.Itoa(n)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// buildTree rebuilds the tree of the folder, or of every mounted folder
+func (s *previewServer) buildTree() (*FolderMeta, error) {
+	if len(s.mounts) == 0 {
+		return buildFolderStructure(s.options, s.vfs, s.folderPath)
+	}
+	return buildMountedStructure(s.options, s.mounts)
+}
+
+// buildMountedStructure lists each mounted folder as a top-level folder
+// item, with its items' paths prefixed by the mount name
+func buildMountedStructure(options vfs.Options, mounts []folderMount) (*FolderMeta, error) {
+	meta := &FolderMeta{Path: "/", IsSecure: true}
+	var names []string
+	for i, m := range mounts {
+		sub, err := buildFolderStructure(options, m.vfs, m.path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.name, err)
+		}
+		children := prefixItems(sub.Items, "/"+m.name, fmt.Sprintf("mount-%d-", i))
+		permissions := m.vfs.Permissions("")
+		meta.Items = append(meta.Items, &FolderItem{
+			ID:          fmt.Sprintf("mount-%d", i),
+			Name:        m.name,
+			Type:        "folder",
+			Path:        "/" + m.name,
+			Children:    children,
+			ChildCount:  len(children),
+			HasChildren: len(children) > 0,
+			Hash:        folderHash(children),
+			Permissions: &permissions,
+		})
+		meta.TotalSize += sub.TotalSize
+		meta.TotalFiles += sub.TotalFiles
+		meta.TotalFolders += sub.TotalFolders + 1
+		names = append(names, m.name)
+	}
+	meta.Name = strings.Join(names, ", ")
+	meta.RecentFiles, meta.LargestFiles = folderSummaries(meta.Items, options.FolderSummarySize)
+	return meta, nil
+}
+
+// prefixItems returns copies of items with prefix in front of their paths
+// and idPrefix in front of their IDs, so items of different folders differ
+func prefixItems(items []*FolderItem, prefix, idPrefix string) []*FolderItem {
+	out := make([]*FolderItem, len(items))
+	for i, item := range items {
+		copied := *item
+		copied.ID = idPrefix + item.ID
+		copied.Path = prefix + item.Path
+		if item.Children != nil {
+			copied.Children = prefixItems(item.Children, prefix, idPrefix)
+		}
+		out[i] = &copied
+	}
+	return out
+}
+
+// search runs a full-text query for the client at remoteIP over every
+// mounted folder, up to limit results in all
+func (s *previewServer) search(ctx context.Context, query, remoteIP string, limit int) ([]vfs.SearchResult, error) {
+	if len(s.mounts) == 0 {
+		return s.vfs.SearchWithIP(ctx, query, remoteIP, limit)
+	}
+	var results []vfs.SearchResult
+	for _, m := range s.mounts {
+		found, err := m.vfs.SearchWithIP(ctx, query, remoteIP, limit-len(results))
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range found {
+			result.Path = m.fullPath(result.Path)
+			results = append(results, result)
+		}
+		if len(results) >= limit {
+			break
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,129 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/oarkflow/previewer/pkg/render"
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// rasterizesPDFs reports whether a real PDF rasterizer is configured
+func (s *previewServer) rasterizesPDFs() bool {
+	if s.options.PDFRasterizer == nil {
+		return false
+	}
+	_, noop := s.options.PDFRasterizer.(render.NoopPDFRasterizer)
+	return !noop
+}
+
+// rasterizedPDF reports whether a file is a PDF that is only shown as page
+// images. The stored MIME type decides, so renaming the file, sniffing or
+// MimeTypeOverrides make no difference.
+func (s *previewServer) rasterizedPDF(info vfs.FileInfo) bool {
+	return s.rasterizesPDFs() && info.MimeType == "application/pdf"
+}
+
+// pdfPagesPage is the preview page of a rasterized PDF: instead of the PDF
+// bytes it embeds the /api/pdf-page URL of the first page ("src") and a
+// "pageSrc" template with "{page}" for the others
+func (s *previewServer) pdfPagesPage(info vfs.FileInfo) ([]byte, string, error) {
+	s.logger.Debug("VFS: generating preview as page images",
+		"path", info.Path, "size", info.Size, "hash", shortHash(info.Hash))
+	base := "/api/pdf-page?path=" + url.QueryEscape(info.Path) + "&page="
+	embeddedFile := embeddedFileMeta(info.Name, info.Size, info.MimeType, info.Hash)
+	embeddedFile["src"] = base + "1"
+	embeddedFile["pageSrc"] = base + "{page}"
+	embeddedFile["rasterized"] = true
+	return s.filePreviewPage(embeddedFile)
+}
+
+// handlePDFPage serves one page of a PDF as an image rendered by the
+// configured PDFRasterizer: GET /api/pdf-page?path=...&page=N (1-based).
+// The page count is returned in X-PDF-Page-Count.
+func (s *previewServer) handlePDFPage(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+	if !s.rasterizesPDFs() {
+		http.Error(w, "PDF rasterization is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		http.Error(w, "Missing file path", http.StatusBadRequest)
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		http.Error(w, "Invalid page number", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requirePreviewSession(w, r) {
+		return
+	}
+
+	clientIP, remoteIP := s.clientIP(r), s.requestIP(r)
+	mount, mountPath, ok := s.resolve(filePath)
+	if !ok {
+		http.Error(w, "Access denied or file not found", http.StatusForbidden)
+		return
+	}
+
+	info, err := mount.vfs.StatWithIP(mountPath, remoteIP)
+	if err != nil {
+		s.logger.Warn("VFS stat error", "path", filePath, "ip", clientIP, "error", err)
+		s.denyFileAccess(w, mount.vfs, err, clientIP)
+		return
+	}
+	if info.MimeType != "application/pdf" {
+		http.Error(w, "Not a PDF file", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vfile, err := mount.vfs.ReadFileContext(r.Context(), mountPath, remoteIP)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("VFS read error", "path", filePath, "ip", clientIP, "error", err)
+		s.denyFileAccess(w, mount.vfs, err, clientIP)
+		return
+	}
+	defer clear(vfile.Data)
+
+	rasterizer := s.options.PDFRasterizer
+	count, err := rasterizer.PageCount(r.Context(), vfile.Data)
+	if err != nil {
+		s.logger.Warn("PDF page count failed", "path", filePath, "error", err)
+		http.Error(w, "Failed to read PDF", http.StatusUnprocessableEntity)
+		return
+	}
+	if page > count {
+		http.Error(w, "Page out of range", http.StatusNotFound)
+		return
+	}
+
+	img, contentType, err := rasterizer.RasterizePage(r.Context(), vfile.Data, page)
+	if err != nil {
+		s.logger.Warn("PDF rasterization failed", "path", filePath, "page", page, "error", err)
+		http.Error(w, "Failed to render PDF page", http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.touch()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(img)))
+	w.Header().Set("X-PDF-Page-Count", strconv.Itoa(count))
+	w.Header().Set("X-File-Hash", info.Hash)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(img)
+}
@@ -0,0 +1,39 @@
+package file
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestPreviewPageRefusesLockedClient(t *testing.T) {
+	options := vfs.DefaultOptions()
+	options.LockAfterFailures = 2
+	srv := newTestFolderServer(t, map[string]string{"a.txt": "plaintext"}, options)
+	handler := srv.folderHandler()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/?folder=x&file=a.txt"); rec.Code != http.StatusOK {
+		t.Fatalf("preview page = %d, want 200", rec.Code)
+	}
+	for range 3 {
+		get("/api/file?path=missing.txt")
+	}
+	rec := get("/?folder=x&file=a.txt")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("preview page for a locked client = %d, want 403", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "cGxhaW50ZXh0") || strings.Contains(rec.Body.String(), "plaintext") {
+		t.Error("preview page for a locked client embeds the file")
+	}
+}
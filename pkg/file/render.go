@@ -0,0 +1,116 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/oarkflow/previewer/assets"
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// RenderIndex injects embedded (served to the viewer as
+// window.__EMBEDDED_FILE__) and security (window.__SECURITY_CONFIG__) into
+// the bundled index.html, in a script tag carrying a fresh nonce. Serve the
+// page with a Content-Security-Policy allowing "script-src 'nonce-<nonce>'".
+// To inject into your own viewer instead, set Options.IndexHTML.
+func RenderIndex(embedded any, security SecurityConfig) ([]byte, string, error) {
+	indexBytes, err := readIndexHTML(vfs.Options{})
+	if err != nil {
+		return nil, "", err
+	}
+	nonce, err := randomNonceBase64(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("nonce: %w", err)
+	}
+	page, err := injectIndex(indexBytes, nonce, embedded, security)
+	if err != nil {
+		return nil, "", err
+	}
+	return page, nonce, nil
+}
+
+// distFS returns Options.AssetsFS if set, or the bundled viewer assets. All
+// asset loading goes through distFS and readIndexHTML.
+func distFS(options vfs.Options) (fs.FS, error) {
+	if options.AssetsFS != nil {
+		return options.AssetsFS, nil
+	}
+	dist, err := fs.Sub(assets.DistFS, "dist")
+	if err != nil {
+		return nil, fmt.Errorf("viewer assets: embedded dist: %w", err)
+	}
+	return dist, nil
+}
+
+// readIndexHTML returns Options.IndexHTML if set, or index.html from the
+// viewer assets. A missing or empty index.html is an error rather than a
+// blank page.
+func readIndexHTML(options vfs.Options) ([]byte, error) {
+	_, indexBytes, err := loadAssets(options)
+	return indexBytes, err
+}
+
+// loadAssets resolves the viewer assets and the unmodified index.html once,
+// so servers can keep them instead of re-reading them per request
+func loadAssets(options vfs.Options) (fs.FS, []byte, error) {
+	dist, err := distFS(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if options.IndexHTML != nil {
+		return dist, options.IndexHTML, nil
+	}
+	indexBytes, err := fs.ReadFile(dist, "index.html")
+	if err != nil {
+		return nil, nil, fmt.Errorf("viewer assets: read index.html: %w", err)
+	}
+	if len(bytes.TrimSpace(indexBytes)) == 0 {
+		return nil, nil, errors.New("viewer assets: index.html is empty")
+	}
+	return dist, indexBytes, nil
+}
+
+// embedData is the "data" field of an embedded file. []byte marshals as
+// base64 without a string copy that couldn't be cleared; nil becomes empty
+// rather than null.
+func embedData(data []byte) []byte {
+	if data == nil {
+		return []byte{}
+	}
+	return data
+}
+
+// injectIndex inserts the embedded data and security config script before
+// </head>. The page is assembled in one buffer, and the intermediate JSON is
+// cleared, so file data embedded in it has no copies other than the page.
+func injectIndex(indexBytes []byte, nonce string, embedded any, security SecurityConfig) ([]byte, error) {
+	embeddedJSON, err := json.Marshal(embedded)
+	if err != nil {
+		return nil, fmt.Errorf("marshal embedded data: %w", err)
+	}
+
+	securityJSON, err := json.Marshal(security)
+	if err != nil {
+		return nil, fmt.Errorf("marshal security config: %w", err)
+	}
+
+	head := bytes.Index(indexBytes, []byte("</head>"))
+	if head < 0 {
+		return nil, errors.New("index.html has no </head> to inject into")
+	}
+	defer clear(embeddedJSON)
+
+	var page bytes.Buffer
+	page.Grow(len(indexBytes) + len(nonce) + len(embeddedJSON) + len(securityJSON) + 96)
+	page.Write(indexBytes[:head])
+	page.WriteString(`<script nonce="` + nonce + `">window.__EMBEDDED_FILE__=`)
+	page.Write(embeddedJSON)
+	page.WriteString(";window.__SECURITY_CONFIG__=")
+	page.Write(securityJSON)
+	page.WriteString(";</script>")
+	page.Write(indexBytes[head:])
+	return page.Bytes(), nil
+}
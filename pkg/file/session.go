@@ -0,0 +1,179 @@
+package file
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// Server-side NoDownload enforcement
+//
+// The UI flags (NoCopy, NoDownload, ScreenshotResistant, Watermark) are
+// enforced by the frontend and are only a deterrent: anything the browser
+// can display, a determined user can capture. The server side of NoDownload
+// is a deterrent too: it makes the bytes harder to get outside the preview
+// UI, e.g. by opening /api/file in a tab and saving it, or by embedding it
+// in another site. When NoDownload is set:
+//
+//   - /api/download is refused outright.
+//   - /api/file, /api/thumbnail, /api/search, /api/diff and /api/tree only
+//     answer requests that carry this server's session cookie (set when the
+//     preview page is loaded), are marked Sec-Fetch-Site: same-origin by
+//     the browser, and are not top-level navigations.
+//
+// This deters casual browser downloads and cross-site embedding only. The
+// cookie is handed to anyone who loads "/", and the Sec-Fetch headers are
+// ordinary headers a script can send, so a script that first fetches the
+// page gets the bytes with one more request. Nor does it stop devtools, a
+// modified client or a screen recorder: the bytes still reach the browser
+// in order to be rendered. To keep files from a party, don't give it access
+// to the server (basic auth, share links, AllowedIPs).
+
+const sessionCookieName = "previewer_session"
+const sessionHeaderName = "X-Preview-Session"
+
+// setSessionCookie hands the preview session token to the browser
+func (s *previewServer) setSessionCookie(w http.ResponseWriter) {
+	if s.sessionToken == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    s.sessionToken,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// hasSessionToken reports whether the request carries this server's session
+// token, either as the cookie or in the X-Preview-Session header
+func (s *previewServer) hasSessionToken(r *http.Request) bool {
+	if s.sessionToken == "" {
+		return false
+	}
+	token := r.Header.Get(sessionHeaderName)
+	if c, err := r.Cookie(sessionCookieName); err == nil && token == "" {
+		token = c.Value
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.sessionToken)) == 1
+}
+
+// rejectPreviewRequest returns why a request for file content does not come
+// from the preview UI, or "" if it does
+func (s *previewServer) rejectPreviewRequest(r *http.Request) string {
+	if !s.hasSessionToken(r) {
+		return "missing or invalid session token"
+	}
+	if r.Header.Get("Sec-Fetch-Site") != "same-origin" {
+		return "not a same-origin browser request"
+	}
+	if r.Header.Get("Sec-Fetch-Mode") == "navigate" || r.Header.Get("Sec-Fetch-Dest") == "document" {
+		return "direct navigation to file content"
+	}
+	return ""
+}
+
+// requirePreviewSession guards content-revealing endpoints when NoDownload
+// is set. It writes a 403 and returns false if the request is refused.
+func (s *previewServer) requirePreviewSession(w http.ResponseWriter, r *http.Request) bool {
+	if !s.securityConfig.NoDownload {
+		return true
+	}
+	if reason := s.rejectPreviewRequest(r); reason != "" {
+		s.logSecurityIncident("download_blocked", "medium", "File request refused: "+reason, map[string]any{
+			"path": r.URL.Query().Get("path"),
+			"ip":   s.clientIP(r),
+		})
+		http.Error(w, "Downloads are disabled for this preview", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireRemoteSession makes every API and WebSocket request of a
+// remote-bound server carry the session token, whatever the security
+// config: the page hands out the cookie, scripts on other machines don't
+// get it. Loopback servers pass requests through unchanged. Admin endpoints
+// have their own token and are left to it.
+func (s *previewServer) requireRemoteSession(next http.Handler) http.Handler {
+	if !s.remote {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gated := (strings.HasPrefix(r.URL.Path, "/api/") && !isAdminRoute(r.URL.Path)) || r.URL.Path == "/ws"
+		if gated && !s.hasSessionToken(r) {
+			s.logSecurityIncident("remote_session_missing", "medium", "Remote request without session token", map[string]any{
+				"route": r.URL.Path,
+				"ip":    s.clientIP(r),
+			})
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sessionTimeoutOrDefault applies the default to an unset session timeout
+func sessionTimeoutOrDefault(d time.Duration) time.Duration {
+	if d <= 0 {
+		return vfs.DefaultSessionTimeout
+	}
+	return d
+}
+
+// startupConnectTimeout applies the default to an unset connect timeout
+func startupConnectTimeout(options vfs.Options) time.Duration {
+	if options.StartupConnectTimeout <= 0 {
+		return vfs.DefaultStartupConnectTimeout
+	}
+	return options.StartupConnectTimeout
+}
+
+// shutdownTimeout applies the default to an unset shutdown timeout
+func shutdownTimeout(options vfs.Options) time.Duration {
+	if options.ShutdownTimeout <= 0 {
+		return vfs.ShutdownTimeout
+	}
+	return options.ShutdownTimeout
+}
+
+// startTimers arms the session timeout and the startup connect timeout.
+// It must be called before the server starts handling requests.
+func (s *previewServer) startTimers() {
+	timeout := sessionTimeoutOrDefault(s.options.SessionTimeout)
+	s.idleTimer = time.AfterFunc(timeout, func() {
+		s.logger.Info("session timed out, shutting down server", "timeout", timeout)
+		s.signalClose()
+	})
+	lead := sessionWarningLead(timeout)
+	s.warnTimer = time.AfterFunc(timeout-lead, func() {
+		s.broadcast(wsEvent{Type: wsEventSessionExpiring, Seconds: int(lead.Seconds())})
+	})
+
+	connectTimeout := startupConnectTimeout(s.options)
+	s.connectTimer = time.AfterFunc(connectTimeout, func() {
+		s.logger.Warn("no browser connected, shutting down server", "timeout", connectTimeout)
+		s.signalClose()
+	})
+}
+
+// touch records activity and pushes the session timeout back
+func (s *previewServer) touch() {
+	timeout := sessionTimeoutOrDefault(s.options.SessionTimeout)
+	if s.idleTimer != nil {
+		s.idleTimer.Reset(timeout)
+	}
+	if s.warnTimer != nil {
+		s.warnTimer.Reset(timeout - sessionWarningLead(timeout))
+	}
+}
+
+// sessionWarningLead is how long before the session timeout browsers are
+// warned: a minute, or half the timeout if that is shorter
+func sessionWarningLead(timeout time.Duration) time.Duration {
+	return min(time.Minute, timeout/2)
+}
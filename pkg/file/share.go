@@ -0,0 +1,209 @@
+package file
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// Share links
+//
+// A share token grants read access to one file, or to the whole folder,
+// until it expires. It is the JSON payload {"p": path, "e": unix expiry}
+// and its HMAC-SHA256 under Options.ShareSecret, both base64url encoded and
+// joined by a dot. Opening "/?token=..." stores the token in a cookie so the
+// page's own requests carry it. A valid token stands in for basic auth; a
+// file token only reaches that file: "/" shows its preview, and the tree,
+// search, other files and other pages (which would embed the tree) are
+// refused.
+
+const shareCookieName = "previewer_share"
+const shareTokenParam = "token"
+
+// ErrShareTokenInvalid is returned for share tokens that are malformed,
+// signed with another secret, or expired
+var ErrShareTokenInvalid = errors.New("invalid or expired share token")
+
+// shareClaims is the signed part of a share token
+type shareClaims struct {
+	Path    string `json:"p"`
+	Expires int64  `json:"e"`
+}
+
+// shareScope is the access granted by a verified share token
+type shareScope struct {
+	Path    string // File the token is limited to; "" is the whole folder
+	Expires time.Time
+}
+
+type shareScopeKey struct{}
+
+// NewShareToken mints a token granting read access to path ("" or "/" for
+// the whole folder) until expires, for a server started with the same
+// Options.ShareSecret
+func NewShareToken(secret []byte, path string, expires time.Time) (string, error) {
+	if len(secret) == 0 {
+		return "", errors.New("share secret is empty")
+	}
+	payload, err := json.Marshal(shareClaims{Path: cleanSharePath(path), Expires: expires.Unix()})
+	if err != nil {
+		return "", fmt.Errorf("marshal share claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(shareMAC(secret, payload)), nil
+}
+
+// verifyShareToken checks the signature and expiry of a share token
+func verifyShareToken(secret []byte, token string, now time.Time) (shareScope, error) {
+	if len(secret) == 0 {
+		return shareScope{}, ErrShareTokenInvalid
+	}
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return shareScope{}, ErrShareTokenInvalid
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(encPayload)
+	if err != nil {
+		return shareScope{}, ErrShareTokenInvalid
+	}
+	mac, err := enc.DecodeString(encMAC)
+	if err != nil || !hmac.Equal(mac, shareMAC(secret, payload)) {
+		return shareScope{}, ErrShareTokenInvalid
+	}
+	var claims shareClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return shareScope{}, ErrShareTokenInvalid
+	}
+	expires := time.Unix(claims.Expires, 0)
+	if !now.Before(expires) {
+		return shareScope{}, ErrShareTokenInvalid
+	}
+	return shareScope{Path: cleanSharePath(claims.Path), Expires: expires}, nil
+}
+
+func shareMAC(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// cleanSharePath normalizes a path the way the VFS keys its files, with the
+// folder root as ""
+func cleanSharePath(path string) string {
+	cleaned := strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+	if cleaned == "." {
+		return ""
+	}
+	return cleaned
+}
+
+// shareToken returns the token of a request, from ?token= or the share
+// cookie, and whether it came from the query
+func shareToken(r *http.Request) (string, bool) {
+	if token := r.URL.Query().Get(shareTokenParam); token != "" {
+		return token, true
+	}
+	if c, err := r.Cookie(shareCookieName); err == nil {
+		return c.Value, false
+	}
+	return "", false
+}
+
+// checkShareToken verifies the request's share token, if it has one and
+// share links are enabled. Tokens from the query are moved to a cookie that
+// expires with them.
+func (s *previewServer) checkShareToken(w http.ResponseWriter, r *http.Request) (shareScope, bool) {
+	if len(s.options.ShareSecret) == 0 {
+		return shareScope{}, false
+	}
+	token, fromQuery := shareToken(r)
+	if token == "" {
+		return shareScope{}, false
+	}
+	scope, err := verifyShareToken(s.options.ShareSecret, token, time.Now())
+	if err != nil {
+		s.logSecurityIncident("share_token_invalid", "medium", "Rejected share token", map[string]any{
+			"route": r.URL.Path,
+			"ip":    s.clientIP(r),
+		})
+		return shareScope{}, false
+	}
+	if fromQuery {
+		http.SetCookie(w, &http.Cookie{
+			Name:     shareCookieName,
+			Value:    token,
+			Path:     "/",
+			Expires:  scope.Expires,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return scope, true
+}
+
+// shareAllows reports whether a request stays within a share token's scope
+func shareAllows(scope shareScope, r *http.Request) bool {
+	if scope.Path == "" {
+		return true
+	}
+	query := r.URL.Query()
+	switch {
+	case r.URL.Path == "/api/tree" || r.URL.Path == "/api/search" || r.URL.Path == "/api/diff":
+		return false
+	case strings.HasPrefix(r.URL.Path, "/api/") && query.Has("path"):
+		return cleanSharePath(query.Get("path")) == scope.Path
+	case r.URL.Path == "/" && query.Has("file"):
+		return cleanSharePath(query.Get("file")) == scope.Path
+	}
+	return true
+}
+
+func withShareScope(ctx context.Context, scope shareScope) context.Context {
+	return context.WithValue(ctx, shareScopeKey{}, scope)
+}
+
+// shareScopeFrom returns the share scope a request was let in with
+func shareScopeFrom(ctx context.Context) (shareScope, bool) {
+	scope, ok := ctx.Value(shareScopeKey{}).(shareScope)
+	return scope, ok
+}
+
+// newShareSecret returns a random key for share links that live as long as
+// the server
+func newShareSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("share secret: %w", err)
+	}
+	return secret, nil
+}
+
+// shareLink mints a token for Options.SharePath and returns the URL to hand
+// out. Wildcard hosts are shown as localhost; replace it with an address the
+// recipient can reach.
+func shareLink(options vfs.Options, port int, expires time.Time) (string, error) {
+	token, err := NewShareToken(options.ShareSecret, options.SharePath, expires)
+	if err != nil {
+		return "", err
+	}
+	host := options.ListenHost
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return fmt.Sprintf("http://%s/?%s=%s", net.JoinHostPort(host, strconv.Itoa(port)), shareTokenParam, url.QueryEscape(token)), nil
+}
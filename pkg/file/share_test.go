@@ -0,0 +1,93 @@
+package file
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// newTestFolderServer writes files into a temporary folder and returns a
+// preview server for it, without listening
+func newTestFolderServer(t *testing.T, files map[string]string, options vfs.Options) *previewServer {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+	fs, err := vfs.NewVirtualFileSystemWithOptions(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fs.SecureCleanup)
+	meta, err := buildFolderStructure(options, fs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := newPreviewServerFromFolder(dir, meta, fs, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestFileShareTokenCannotFetchIndex(t *testing.T) {
+	options := vfs.DefaultOptions()
+	options.ShareSecret = []byte("0123456789abcdef0123456789abcdef")
+	srv := newTestFolderServer(t, map[string]string{
+		"shared.txt":      "shared",
+		"private/key.txt": "secret",
+	}, options)
+	handler := srv.folderHandler()
+
+	token, err := NewShareToken(options.ShareSecret, "shared.txt", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{Name: shareCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	// "/" opens the shared file only
+	code, body := get("/")
+	if code != http.StatusOK {
+		t.Fatalf("GET / = %d, want 200", code)
+	}
+	if strings.Contains(body, "key.txt") || strings.Contains(body, "private") {
+		t.Errorf("GET / with a file token reveals other files")
+	}
+
+	for _, target := range []string{"/x", "/some/deep/route", "/?folder=x"} {
+		code, body := get(target)
+		if strings.Contains(body, "key.txt") || strings.Contains(body, "private") {
+			t.Errorf("GET %s with a file token reveals other files (status %d)", target, code)
+		}
+		if code == http.StatusOK && target != "/?folder=x" {
+			t.Errorf("GET %s = 200, want 403", target)
+		}
+	}
+	if code, _ := get("/api/tree"); code != http.StatusForbidden {
+		t.Errorf("GET /api/tree = %d, want 403", code)
+	}
+}
@@ -0,0 +1,76 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// Run with -race: requests arriving while the server starts serving, and
+// while the tree is rebuilt, must not race with the fields they read
+func TestRequestsDuringStartup(t *testing.T) {
+	srv := newTestFolderServer(t, map[string]string{
+		"a.txt":     "alpha",
+		"dir/b.txt": "beta",
+	}, vfs.DefaultOptions())
+
+	ts := httptest.NewUnstartedServer(srv.folderHandler())
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	ts.Start()
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for _, path := range []string{"/", "/api/tree", "/?folder=x&file=a.txt", "/api/file?path=dir/b.txt"} {
+				resp, err := http.Get(ts.URL + path)
+				if err != nil {
+					t.Errorf("GET %s: %v", path, err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode >= http.StatusInternalServerError {
+					t.Errorf("GET %s (client %d): status %d", path, i, resp.StatusCode)
+				}
+			}
+		}()
+	}
+	close(start)
+	for range 3 {
+		srv.reloadFolder()
+	}
+	wg.Wait()
+}
+
+func TestServeErrorEndsPreview(t *testing.T) {
+	srv := newTestFolderServer(t, map[string]string{"a.txt": "alpha"}, vfs.DefaultOptions())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close() // Serve fails at once
+
+	go srv.serve(&http.Server{Handler: srv.folderHandler()}, listener)
+	if err := srv.waitForClose(); err == nil {
+		t.Fatal("waitForClose returned no error for a failed server")
+	}
+}
+
+func TestPreviewFileMissing(t *testing.T) {
+	err := PreviewFile(filepath.Join(t.TempDir(), "missing.txt"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("PreviewFile error = %v, want one wrapping os.ErrNotExist", err)
+	}
+}
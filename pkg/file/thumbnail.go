@@ -0,0 +1,234 @@
+package file
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const defaultThumbnailMaxDimension = 256
+const maxThumbnailSourcePixels = 50 * 1000 * 1000 // Refuse to decode images above 50 megapixels
+const thumbnailQuality = 80
+const maxThumbCacheBytes = 32 * 1024 * 1024 // Thumbnails kept in memory; the least recently used go first
+
+// thumbCache holds generated thumbnails up to maxThumbCacheBytes, dropping
+// the least recently used ones. The zero value is an empty cache.
+type thumbCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // Key -> element of lru
+	lru     list.List                // *thumbEntry, most recently used first
+	size    int                      // Bytes of all cached thumbnails
+}
+
+type thumbEntry struct {
+	key  string
+	data []byte
+}
+
+// get returns the thumbnail cached under key, marking it recently used
+func (c *thumbCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*thumbEntry).data, true
+}
+
+// put caches a thumbnail, evicting the least recently used ones to stay
+// within maxThumbCacheBytes. Thumbnails larger than that are not cached.
+func (c *thumbCache) put(key string, data []byte) {
+	if len(data) > maxThumbCacheBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if el, ok := c.entries[key]; ok {
+		c.size -= len(el.Value.(*thumbEntry).data)
+		c.lru.Remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&thumbEntry{key: key, data: data})
+	c.size += len(data)
+	for c.size > maxThumbCacheBytes {
+		oldest := c.lru.Back()
+		entry := oldest.Value.(*thumbEntry)
+		c.lru.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.data)
+	}
+}
+
+// drop empties the cache. Thumbnails being written keep their bytes.
+func (c *thumbCache) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.lru.Init()
+	c.size = 0
+}
+
+// wipe empties the cache and zeroes the thumbnails, for shutdown
+func (c *thumbCache) wipe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		clear(el.Value.(*thumbEntry).data)
+	}
+	c.entries = nil
+	c.lru.Init()
+	c.size = 0
+}
+
+// handleThumbnail serves a downscaled JPEG of an image stored in the VFS.
+// Only image/jpeg and image/png are supported; anything else gets 415.
+func (s *previewServer) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		http.Error(w, "Missing file path", http.StatusBadRequest)
+		return
+	}
+
+	if !s.requirePreviewSession(w, r) {
+		return
+	}
+
+	clientIP, remoteIP := s.clientIP(r), s.requestIP(r)
+	mount, mountPath, ok := s.resolve(filePath)
+	if !ok {
+		http.Error(w, "Access denied or file not found", http.StatusForbidden)
+		return
+	}
+
+	// Check the type from metadata before paying for decryption
+	info, err := mount.vfs.StatWithIP(mountPath, remoteIP)
+	if err != nil {
+		s.logger.Warn("VFS stat error", "path", filePath, "ip", clientIP, "error", err)
+		s.denyFileAccess(w, mount.vfs, err, clientIP)
+		return
+	}
+	if info.MimeType != "image/jpeg" && info.MimeType != "image/png" {
+		http.Error(w, "Thumbnails are only available for JPEG and PNG images", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	maxDim := s.options.ThumbnailMaxDimension
+	if maxDim <= 0 {
+		maxDim = defaultThumbnailMaxDimension
+	}
+	cacheKey := mount.fullPath(info.Path) + "\x00" + info.Hash + "\x00" + strconv.Itoa(maxDim)
+
+	thumb, ok := s.thumbs.get(cacheKey)
+	if !ok {
+		vfile, err := mount.vfs.ReadFileContext(r.Context(), mountPath, remoteIP)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			s.logger.Warn("VFS read error", "path", filePath, "ip", clientIP, "error", err)
+			s.denyFileAccess(w, mount.vfs, err, clientIP)
+			return
+		}
+
+		thumb, err = makeThumbnail(vfile.Data, maxDim)
+		clear(vfile.Data)
+		if err != nil {
+			s.logger.Warn("thumbnail generation failed", "path", filePath, "error", err)
+			http.Error(w, "Failed to generate thumbnail", http.StatusUnprocessableEntity)
+			return
+		}
+
+		s.thumbs.put(cacheKey, thumb)
+	}
+
+	s.touch()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(thumb)))
+	w.Header().Set("X-File-Hash", info.Hash)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(thumb)
+}
+
+// makeThumbnail decodes a JPEG or PNG and re-encodes it as a JPEG whose
+// longest side is at most maxDim pixels. Smaller images are not upscaled.
+func makeThumbnail(data []byte, maxDim int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxThumbnailSourcePixels {
+		return nil, fmt.Errorf("image dimensions %dx%d not supported", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(src, maxDim), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown resizes src so its longest side is at most maxDim, averaging a
+// small grid of source samples per destination pixel
+func scaleDown(src image.Image, maxDim int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > maxDim || h > maxDim {
+		if w >= h {
+			dw, dh = maxDim, max(1, h*maxDim/w)
+		} else {
+			dw, dh = max(1, w*maxDim/h), maxDim
+		}
+	}
+
+	const samples = 4 // per axis
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var r, g, bl, a uint32
+			for sy := 0; sy < samples; sy++ {
+				py := b.Min.Y + (y*samples+sy)*h/(dh*samples)
+				for sx := 0; sx < samples; sx++ {
+					px := b.Min.X + (x*samples+sx)*w/(dw*samples)
+					cr, cg, cb, ca := src.At(px, py).RGBA()
+					r, g, bl, a = r+cr, g+cg, bl+cb, a+ca
+				}
+			}
+			// Colours are alpha-premultiplied; composite onto white since
+			// JPEG has no transparency
+			n := uint32(samples * samples)
+			bg := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + bg),
+				G: uint16(g/n + bg),
+				B: uint16(bl/n + bg),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,60 @@
+package file
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestThumbCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	var c thumbCache
+	chunk := maxThumbCacheBytes/3 + 1 // Three are over the limit
+	a, b := bytes.Repeat([]byte{'a'}, chunk), bytes.Repeat([]byte{'b'}, chunk)
+	c.put("a", a)
+	c.put("b", b)
+	if _, ok := c.get("a"); !ok { // a is now more recent than b
+		t.Fatal("a not cached")
+	}
+	c.put("c", bytes.Repeat([]byte{'c'}, chunk))
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b, the least recently used, was kept past the byte limit")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("%s evicted", key)
+		}
+	}
+	if c.size > maxThumbCacheBytes {
+		t.Errorf("cache holds %d bytes, limit %d", c.size, maxThumbCacheBytes)
+	}
+
+	c.put("huge", make([]byte, maxThumbCacheBytes+1))
+	if _, ok := c.get("huge"); ok {
+		t.Error("a thumbnail over the limit was cached")
+	}
+
+	c.drop()
+	if _, ok := c.get("a"); ok || c.size != 0 {
+		t.Fatalf("drop left entries (%d bytes)", c.size)
+	}
+	if a[0] != 'a' {
+		t.Fatal("drop zeroed a thumbnail")
+	}
+
+	c.put("a", a)
+	c.wipe()
+	if _, ok := c.get("a"); ok || bytes.ContainsRune(a, 'a') {
+		t.Fatal("wipe left the thumbnail cached or unzeroed")
+	}
+}
+
+func TestReloadDropsThumbnails(t *testing.T) {
+	srv := newTestFolderServer(t, map[string]string{"a.txt": "a"}, vfs.DefaultOptions())
+	srv.thumbs.put("a.png\x00hash\x00256", []byte("jpeg"))
+	srv.reloadFolder()
+	if _, ok := srv.thumbs.get("a.png\x00hash\x00256"); ok {
+		t.Fatal("thumbnail cached across a reload")
+	}
+}
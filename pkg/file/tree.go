@@ -0,0 +1,281 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// handleTree serves the folder tree as JSON, so the viewer can refresh it
+// without reloading the page. Items the requester cannot read are left out.
+// With ?path= only the direct children of that folder are returned, for
+// lazily expanding folders (see Options.LazyTree). Items come back in
+// directory order unless ?sort=name|size|modified is given; ?order=desc
+// reverses it and ?foldersFirst=true lists folders before files.
+func (s *previewServer) handleTree(w http.ResponseWriter, r *http.Request) {
+	if s.vfs == nil {
+		http.Error(w, "Not in folder preview mode", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requirePreviewSession(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	less, err := treeOrder(query.Get("sort"), query.Get("order"), query.Get("foldersFirst"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.indexMu.RLock()
+	tree := readableTree(s.folderMeta)
+	s.indexMu.RUnlock()
+
+	if r.URL.Query().Has("path") {
+		folderPath := r.URL.Query().Get("path")
+		if err := s.vfs.ValidatePath(folderPath); err != nil && strings.Trim(folderPath, "/") != "" {
+			http.Error(w, "Invalid path", http.StatusBadRequest)
+			return
+		}
+		folder := subtree(tree, folderPath)
+		if folder == nil {
+			http.Error(w, "Folder not found", http.StatusNotFound)
+			return
+		}
+		tree = shallowTree(folder)
+	}
+	if less != nil {
+		sortItems(tree.Items, less)
+	}
+	s.touch()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(tree)
+}
+
+// readableTree returns a copy of meta without the items whose permissions
+// deny reading, with the totals recomputed for what is left
+func readableTree(meta *FolderMeta) *FolderMeta {
+	out := *meta
+	out.Items, out.TotalSize, out.TotalFiles, out.TotalFolders = readableItems(meta.Items)
+	out.RecentFiles = readableFiles(meta.RecentFiles)
+	out.LargestFiles = readableFiles(meta.LargestFiles)
+	return &out
+}
+
+// readableFiles filters a summary list by read permission
+func readableFiles(items []*FolderItem) []*FolderItem {
+	var kept []*FolderItem
+	for _, item := range items {
+		if item.Permissions == nil || item.Permissions.CanRead {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// embeddedTree returns the readable tree embedded into the page: all of it,
+// or only the top-level items when Options.LazyTree is set
+func embeddedTree(meta *FolderMeta, options vfs.Options) *FolderMeta {
+	meta = readableTree(meta)
+	if !options.LazyTree {
+		return meta
+	}
+	return shallowTree(meta)
+}
+
+// shallowTree returns a copy of meta without the children of its items.
+// HasChildren and ChildCount still tell the viewer which folders expand.
+func shallowTree(meta *FolderMeta) *FolderMeta {
+	out := *meta
+	out.Items = make([]*FolderItem, len(meta.Items))
+	for i, item := range meta.Items {
+		copied := *item
+		copied.Children = nil
+		out.Items[i] = &copied
+	}
+	return &out
+}
+
+// subtree returns the folder at folderPath as a FolderMeta, or nil if there
+// is no such folder in meta
+func subtree(meta *FolderMeta, folderPath string) *FolderMeta {
+	folderPath = path.Clean("/" + strings.TrimPrefix(folderPath, "/"))
+	if folderPath == "/" {
+		return meta
+	}
+	items := meta.Items
+	for _, name := range strings.Split(strings.TrimPrefix(folderPath, "/"), "/") {
+		var found *FolderItem
+		for _, item := range items {
+			if item.Name == name && item.Type == "folder" {
+				found = item
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		if found.Path == folderPath {
+			sub, size, files, folders := readableItems(found.Children)
+			return &FolderMeta{
+				Path:         found.Path,
+				Name:         found.Name,
+				Items:        sub,
+				TotalSize:    size,
+				TotalFiles:   files,
+				TotalFolders: folders,
+				LastMod:      found.LastMod,
+				IsSecure:     meta.IsSecure,
+			}
+		}
+		items = found.Children
+	}
+	return nil
+}
+
+func readableItems(items []*FolderItem) ([]*FolderItem, int64, int, int) {
+	var kept []*FolderItem
+	var totalSize int64
+	var totalFiles, totalFolders int
+	for _, item := range items {
+		if item.Permissions != nil && !item.Permissions.CanRead {
+			continue
+		}
+		copied := *item
+		if item.Type == "folder" {
+			var size int64
+			var files, folders int
+			copied.Children, size, files, folders = readableItems(item.Children)
+			totalSize += size
+			totalFiles += files
+			totalFolders += folders + 1
+		} else {
+			totalSize += item.Size
+			totalFiles++
+		}
+		kept = append(kept, &copied)
+	}
+	if kept == nil {
+		kept = []*FolderItem{}
+	}
+	return kept, totalSize, totalFiles, totalFolders
+}
+
+// folderHash combines the names and hashes of a folder's items into one
+// SHA-256, so two folders with the same contents hash the same
+func folderHash(items []*FolderItem) string {
+	h := sha256.New()
+	for _, item := range items {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\n", item.Type, item.Name, item.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// treeOrder returns the comparison for the /api/tree sort parameters, or nil
+// to keep directory order
+func treeOrder(by, order, foldersFirst string) (func(a, b *FolderItem) bool, error) {
+	var less func(a, b *FolderItem) bool
+	switch by {
+	case "":
+	case "name":
+		less = func(a, b *FolderItem) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "size":
+		less = func(a, b *FolderItem) bool { return a.Size < b.Size }
+	case "modified":
+		less = func(a, b *FolderItem) bool { return a.LastMod < b.LastMod }
+	default:
+		return nil, fmt.Errorf("unknown sort %q (want name, size or modified)", by)
+	}
+
+	switch order {
+	case "", "asc":
+	case "desc":
+		if less != nil {
+			asc := less
+			less = func(a, b *FolderItem) bool { return asc(b, a) }
+		}
+	default:
+		return nil, fmt.Errorf("unknown order %q (want asc or desc)", order)
+	}
+
+	if foldersFirst != "" {
+		group, err := strconv.ParseBool(foldersFirst)
+		if err != nil {
+			return nil, fmt.Errorf("invalid foldersFirst %q", foldersFirst)
+		}
+		if group {
+			within := less
+			less = func(a, b *FolderItem) bool {
+				if (a.Type == "folder") != (b.Type == "folder") {
+					return a.Type == "folder"
+				}
+				return within != nil && within(a, b)
+			}
+		}
+	}
+	return less, nil
+}
+
+// sortItems sorts items and all their children in place. The stable sort
+// keeps directory order between items that compare equal.
+func sortItems(items []*FolderItem, less func(a, b *FolderItem) bool) {
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+	for _, item := range items {
+		if len(item.Children) > 0 {
+			sortItems(item.Children, less)
+		}
+	}
+}
+
+const defaultFolderSummarySize = 10
+
+// folderSummaries returns the n most recently modified and the n largest
+// files under items (0 means the default, negative disables them)
+func folderSummaries(items []*FolderItem, n int) (recent, largest []*FolderItem) {
+	if n < 0 {
+		return nil, nil
+	}
+	if n == 0 {
+		n = defaultFolderSummarySize
+	}
+
+	var files []*FolderItem
+	var collect func(items []*FolderItem)
+	collect = func(items []*FolderItem) {
+		for _, item := range items {
+			if item.Type == "folder" {
+				collect(item.Children)
+			} else if item.Skipped == "" {
+				files = append(files, item)
+			}
+		}
+	}
+	collect(items)
+
+	top := func(less func(a, b *FolderItem) bool) []*FolderItem {
+		sorted := append([]*FolderItem(nil), files...)
+		sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+		if len(sorted) > n {
+			sorted = sorted[:n]
+		}
+		return sorted
+	}
+	recent = top(func(a, b *FolderItem) bool { return a.LastMod > b.LastMod })
+	largest = top(func(a, b *FolderItem) bool { return a.Size > b.Size })
+	return recent, largest
+}
@@ -0,0 +1,50 @@
+package file
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+func TestTreeLeavesOutUnreadable(t *testing.T) {
+	options := vfs.DefaultOptions()
+	options.EnableSearchIndex = true
+	srv := newTestFolderServer(t, map[string]string{
+		vfs.PermissionsFileName: `{"secret.txt": {"canRead": false}, "private": {"canRead": false}}`,
+		"public.txt":            "needle public",
+		"secret.txt":            "needle secret",
+		"private/key.txt":       "needle key",
+	}, options)
+
+	var paths []string
+	var walk func(items []*FolderItem)
+	walk = func(items []*FolderItem) {
+		for _, item := range items {
+			paths = append(paths, item.Path)
+			walk(item.Children)
+		}
+	}
+	walk(srv.folderMeta.Items)
+	if !slices.Equal(paths, []string{"/public.txt"}) {
+		t.Fatalf("tree has %q, want only /public.txt", paths)
+	}
+	if srv.folderMeta.TotalFiles != 1 || srv.folderMeta.TotalFolders != 0 {
+		t.Fatalf("tree counts %d files and %d folders, want 1 and 0", srv.folderMeta.TotalFiles, srv.folderMeta.TotalFolders)
+	}
+	for _, summary := range [][]*FolderItem{srv.folderMeta.RecentFiles, srv.folderMeta.LargestFiles} {
+		for _, item := range summary {
+			if item.Path != "/public.txt" {
+				t.Errorf("summary lists %q", item.Path)
+			}
+		}
+	}
+
+	results, err := srv.vfs.Search(t.Context(), "needle", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "public.txt" {
+		t.Fatalf("search found %+v, want only public.txt", results)
+	}
+}
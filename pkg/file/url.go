@@ -0,0 +1,100 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// defaultURLDownloadTimeout bounds a PreviewURL download when the context
+// has no deadline of its own
+const defaultURLDownloadTimeout = 2 * time.Minute
+
+// PreviewURL downloads a file over HTTP(S) and previews it like Preview. The
+// name shown comes from the URL's last path segment; when its extension
+// doesn't give a MIME type, the response's Content-Type is used. Downloads
+// larger than the default MaxFileSize are refused, and it is enforced while
+// reading, so a missing or false Content-Length can't exhaust memory.
+func PreviewURL(ctx context.Context, rawURL string) error {
+	name, mimeType, data, err := downloadURL(ctx, rawURL, vfs.DefaultOptions().MaxFileSize)
+	if err != nil {
+		return err
+	}
+	defer clear(data)
+	return previewBytes(name, data, PreviewOptions{MimeType: mimeType})
+}
+
+// downloadURL fetches rawURL, reading at most maxSize bytes
+func downloadURL(ctx context.Context, rawURL string, maxSize int64) (string, string, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("parse URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultURLDownloadTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("build request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", nil, fmt.Errorf("download: %s", resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return "", "", nil, fmt.Errorf("download: %d bytes exceeds the %d byte limit", resp.ContentLength, maxSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("download: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return "", "", nil, errors.New("download: response exceeds the size limit")
+	}
+
+	name := urlFileName(resp.Request.URL)
+	mimeType := ""
+	if vfs.MimeTypeByExtension(name, nil) == "" {
+		mimeType = responseMimeType(resp.Header.Get("Content-Type"))
+	}
+	return name, mimeType, data, nil
+}
+
+// urlFileName returns the last path segment of u (after redirects), or
+// "file" for URLs without one
+func urlFileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." || name == "" {
+		return "file"
+	}
+	return name
+}
+
+// responseMimeType returns the media type of a Content-Type header, or ""
+// when it is missing or only says "some bytes"
+func responseMimeType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" {
+		return ""
+	}
+	return mediaType
+}
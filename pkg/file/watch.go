@@ -0,0 +1,49 @@
+package file
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// currentIndex returns the injected index.html being served
+func (s *previewServer) currentIndex() []byte {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	return s.indexHTML
+}
+
+// reloadFolder rebuilds the folder tree after the VFS was reloaded and tells
+// connected browsers to refresh
+func (s *previewServer) reloadFolder() {
+	folderMeta, err := s.buildTree()
+	if err != nil {
+		s.logger.Warn("rebuild folder structure", "error", err)
+		return
+	}
+	index, err := injectFolderIndex(s.indexTemplate, s.cspNonce, embeddedTree(folderMeta, s.options), s.securityConfig)
+	if err != nil {
+		s.logger.Warn("render folder index", "error", err)
+		return
+	}
+
+	s.indexMu.Lock()
+	s.indexHTML = index
+	s.folderMeta = folderMeta
+	s.indexMu.Unlock()
+	s.thumbs.drop() // Thumbnails of changed or removed files are dead weight
+
+	s.logger.Info("folder changed, refreshing browsers", "files", folderMeta.TotalFiles)
+	s.broadcast(wsEvent{Type: wsEventTreeUpdated, Files: folderMeta.TotalFiles})
+	s.broadcastReload()
+}
+
+// broadcastReload sends the reload message and closes every connection; the
+// bundled UI reloads the page when its socket closes. Shutdown on the last
+// close is suppressed while the browsers reconnect.
+func (s *previewServer) broadcastReload() {
+	if len(s.clients()) == 0 {
+		return
+	}
+	s.reloading.Store(true)
+	s.broadcast(wsEvent{Type: wsEventReload})
+	s.closeClients(websocket.CloseServiceRestart, "reload")
+}
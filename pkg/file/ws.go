@@ -0,0 +1,223 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/oarkflow/previewer/pkg/vfs"
+)
+
+// WebSocket protocol
+//
+// Clients send JSON objects with a "type":
+//
+//	{"type":"ping"}                          -> {"type":"pong"}
+//	{"type":"heartbeat"}                     -> {"type":"ack","ack":"heartbeat"}
+//	{"type":"violation","detail":{...}}      -> {"type":"ack","ack":"violation"}, then the server closes
+//	{"type":"close"}                         -> the server closes
+//
+// Unknown types get {"type":"error","error":"..."}. The plaintext messages
+// of the first protocol ("ping", "close", "closing", "violation...") are
+// still accepted, without acks.
+//
+// The server pushes events to every open connection:
+//
+//	{"type":"tree_updated","files":N}        the folder changed on disk (see /api/tree)
+//	{"type":"reload"}                        followed by a close; the page should reload
+//	{"type":"session_expiring","seconds":N}  the session times out unless there is activity
+//	{"type":"force_close","reason":"..."}    the server is shutting down
+
+const maxWSMessageBytes = 64 * 1024 // Largest client message; bigger ones close the connection
+const wsWriteTimeout = time.Second
+const defaultWSPingInterval = 20 * time.Second
+
+// Server-to-client event types
+const (
+	wsEventTreeUpdated     = "tree_updated"
+	wsEventReload          = "reload"
+	wsEventSessionExpiring = "session_expiring"
+	wsEventForceClose      = "force_close"
+)
+
+// wsEvent is a server-to-client WebSocket message
+type wsEvent struct {
+	Type    string `json:"type"`
+	Files   int    `json:"files,omitempty"`
+	Seconds int    `json:"seconds,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// wsClient is an open WebSocket connection. gorilla allows one concurrent
+// writer per connection, so all writes go through writeMu.
+type wsClient struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+// writeJSON sends v as a JSON text message
+func (c *wsClient) writeJSON(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(v)
+}
+
+// close sends a close frame; the read loop in handleWS then ends
+func (c *wsClient) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteTimeout))
+}
+
+// wsPingIntervalOrDefault applies the default to Options.WSPingInterval
+func wsPingIntervalOrDefault(options vfs.Options) time.Duration {
+	if options.WSPingInterval <= 0 {
+		return defaultWSPingInterval
+	}
+	return options.WSPingInterval
+}
+
+// extendDeadline gives the client another three ping intervals to show it is
+// alive
+func (c *wsClient) extendDeadline(options vfs.Options) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(3 * wsPingIntervalOrDefault(options)))
+}
+
+// keepAlive pings the client on an interval so a browser that went away
+// without a close frame (killed tab, sleeping laptop) is noticed: the read
+// deadline passes, ReadMessage fails and handleWS cleans up. It returns a
+// function that stops the pings.
+func (s *previewServer) keepAlive(client *wsClient) func() {
+	interval := wsPingIntervalOrDefault(s.options)
+	client.extendDeadline(s.options)
+	client.conn.SetPongHandler(func(string) error {
+		client.extendDeadline(s.options)
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				client.writeMu.Lock()
+				err := client.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+				client.writeMu.Unlock()
+				if err != nil {
+					s.logger.Debug("ws ping", "error", err)
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// trackConn adds or removes an open WebSocket connection and returns the
+// number of connections left open
+func (s *previewServer) trackConn(client *wsClient, open bool) int {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	if !open {
+		delete(s.wsConns, client)
+		return len(s.wsConns)
+	}
+	if s.wsConns == nil {
+		s.wsConns = make(map[*wsClient]struct{})
+	}
+	s.wsConns[client] = struct{}{}
+	return len(s.wsConns)
+}
+
+// clients returns the open connections
+func (s *previewServer) clients() []*wsClient {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	clients := make([]*wsClient, 0, len(s.wsConns))
+	for c := range s.wsConns {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// broadcast pushes an event to every open connection. A slow client only
+// holds up its own write, for at most wsWriteTimeout.
+func (s *previewServer) broadcast(event wsEvent) {
+	for _, c := range s.clients() {
+		if err := c.writeJSON(event); err != nil {
+			s.logger.Debug("ws broadcast", "type", event.Type, "error", err)
+		}
+	}
+}
+
+// closeClients sends a close frame to every open connection
+func (s *previewServer) closeClients(code int, reason string) {
+	for _, c := range s.clients() {
+		c.close(code, reason)
+	}
+}
+
+// wsMessage is a client-to-server WebSocket message
+type wsMessage struct {
+	Type   string         `json:"type"`
+	Detail map[string]any `json:"detail,omitempty"`
+}
+
+// handleWSMessage acts on one client message and reports whether the
+// connection should stay open
+func (s *previewServer) handleWSMessage(ctx context.Context, client *wsClient, msg []byte) bool {
+	trimmed := strings.TrimSpace(string(msg))
+	if !strings.HasPrefix(trimmed, "{") {
+		return s.handleLegacyWSMessage(strings.ToLower(trimmed))
+	}
+
+	var m wsMessage
+	if err := json.Unmarshal([]byte(trimmed), &m); err != nil {
+		s.writeWS(client, map[string]any{"type": "error", "error": "invalid JSON message"})
+		return true
+	}
+	switch m.Type {
+	case "ping":
+		s.writeWS(client, map[string]any{"type": "pong"})
+	case "heartbeat":
+		s.writeWS(client, map[string]any{"type": "ack", "ack": "heartbeat"})
+	case "close", "closing":
+		return false
+	case "violation":
+		s.reportFrontendIncident(ctx, "security_violation", "high", "Security violation reported over WebSocket", m.Detail)
+		s.writeWS(client, map[string]any{"type": "ack", "ack": "violation"})
+		return false
+	default:
+		s.writeWS(client, map[string]any{"type": "error", "error": "unknown message type " + truncate(m.Type, 64)})
+	}
+	return true
+}
+
+// handleLegacyWSMessage handles the plaintext messages of the first protocol
+func (s *previewServer) handleLegacyWSMessage(m string) bool {
+	switch {
+	case m == "ping":
+		return true
+	case m == "close" || m == "closing":
+		return false
+	case strings.HasPrefix(m, "violation") || strings.Contains(m, "security_violation"):
+		s.logger.Warn("security violation reported by client", "message", truncate(m, maxIncidentMessageLength))
+		return false
+	}
+	return true
+}
+
+// writeWS answers a client message
+func (s *previewServer) writeWS(client *wsClient, v any) {
+	if err := client.writeJSON(v); err != nil {
+		s.logger.Debug("ws write", "error", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package render defines pluggable server-side renderers used by the preview
+// server to avoid sending original file bytes to the browser.
+package render
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrUnsupported is returned by the no-op renderers
+var ErrUnsupported = errors.New("render: not supported")
+
+// PDFRasterizer turns PDF pages into images. Implementations can wrap a
+// library or an external tool such as pdftoppm or mutool.
+type PDFRasterizer interface {
+	// PageCount returns the number of pages in the document
+	PageCount(ctx context.Context, pdf []byte) (int, error)
+	// RasterizePage renders the 1-based page and returns the encoded image
+	// and its MIME type (e.g. "image/png")
+	RasterizePage(ctx context.Context, pdf []byte, page int) ([]byte, string, error)
+}
+
+// NoopPDFRasterizer is the default rasterizer; it supports nothing
+type NoopPDFRasterizer struct{}
+
+// PageCount always returns ErrUnsupported
+func (NoopPDFRasterizer) PageCount(ctx context.Context, pdf []byte) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// RasterizePage always returns ErrUnsupported
+func (NoopPDFRasterizer) RasterizePage(ctx context.Context, pdf []byte, page int) ([]byte, string, error) {
+	return nil, "", ErrUnsupported
+}
+
+// DocumentConverter turns documents the viewer cannot render, such as
+// office files, into PDF. Implementations can wrap an external tool such as
+// LibreOffice (soffice --convert-to pdf) or a conversion service.
+type DocumentConverter interface {
+	// ToPDF converts data of the given MIME type and returns the PDF
+	ToPDF(ctx context.Context, mimeType string, data []byte) ([]byte, error)
+}
+
+// NoopDocumentConverter is the default converter; it supports nothing
+type NoopDocumentConverter struct{}
+
+// ToPDF always returns ErrUnsupported
+func (NoopDocumentConverter) ToPDF(ctx context.Context, mimeType string, data []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+// officeMimeTypes are the document types handed to a DocumentConverter
+var officeMimeTypes = map[string]bool{
+	"application/msword":            true,
+	"application/vnd.ms-excel":      true,
+	"application/vnd.ms-powerpoint": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
+	"application/vnd.oasis.opendocument.text":                                   true,
+	"application/vnd.oasis.opendocument.spreadsheet":                            true,
+	"application/vnd.oasis.opendocument.presentation":                           true,
+	"application/rtf": true,
+}
+
+// IsOfficeType reports whether mimeType is a word processing, spreadsheet
+// or presentation format that is previewed by converting it to PDF
+func IsOfficeType(mimeType string) bool {
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	return officeMimeTypes[strings.TrimSpace(strings.ToLower(mediaType))]
+}
@@ -0,0 +1,35 @@
+package vfs
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// anonymizedIPPrefix marks client IPs replaced by AnonymizeIP
+const anonymizedIPPrefix = "anon-"
+
+// newIPSalt returns the per-process secret used to anonymize client IPs.
+// It is never stored, so tokens cannot be linked across runs.
+func newIPSalt() []byte {
+	salt := make([]byte, 32)
+	_, _ = rand.Read(salt)
+	return salt
+}
+
+// AnonymizeIP returns the form of a client IP that is stored in access
+// records and logged. With Options.AnonymizeIPs this is a keyed hash: the
+// same IP always maps to the same token within this VFS, so per-IP anomaly
+// detection keeps working, but the address itself cannot be recovered.
+// Without the option, and for tokens that are already anonymized, ip is
+// returned unchanged.
+func (vfs *VirtualFileSystem) AnonymizeIP(ip string) string {
+	if !vfs.options.AnonymizeIPs || ip == "" || strings.HasPrefix(ip, anonymizedIPPrefix) {
+		return ip
+	}
+	mac := hmac.New(sha256.New, vfs.ipSalt)
+	mac.Write([]byte(ip))
+	return anonymizedIPPrefix + hex.EncodeToString(mac.Sum(nil)[:8])
+}
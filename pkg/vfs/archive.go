@@ -0,0 +1,181 @@
+package vfs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NewVirtualFileSystemFromArchive creates a VFS from the files of the zip
+// archive at zipPath. Entries go through the same path checks, ignore rules,
+// size limits and encryption pipeline as files loaded from a folder;
+// encrypted entries are decrypted with Options.ArchivePassword. Like
+// NewVirtualFileSystemFromMap, the result has no source folder to Reload.
+func NewVirtualFileSystemFromArchive(zipPath string, options Options) (*VirtualFileSystem, error) {
+	// newVirtualFileSystem drops the password with the other secrets
+	password := options.ArchivePassword
+	return newVirtualFileSystem("", options, func(vfs *VirtualFileSystem) error {
+		return vfs.loadArchive(zipPath, password)
+	})
+}
+
+// loadArchive stores every file of a zip archive
+func (vfs *VirtualFileSystem) loadArchive(zipPath, password string) error {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer archive.Close()
+
+	filter, err := NewPathFilter("", vfs.options.Exclude)
+	if err != nil {
+		return fmt.Errorf("failed to read ignore rules: %w", err)
+	}
+	vfs.filter = filter
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := vfs.ValidatePath(f.Name); err != nil {
+			vfs.logger().Warn("skipping archive entry", "path", f.Name, "error", err)
+			vfs.skipFile(f.Name, SkipUnreadable+": "+err.Error())
+			continue
+		}
+		relPath := normalizePath(f.Name)
+		if reason := vfs.skipArchivePath(relPath); reason != "" {
+			vfs.skipFile(relPath, reason)
+			continue
+		}
+
+		size := int64(f.UncompressedSize64)
+		if size > vfs.options.MaxFileSize {
+			vfs.logger().Warn("skipping file: exceeds max size",
+				"path", relPath, "max_mb", vfs.options.MaxFileSize/(1024*1024))
+			vfs.skipFile(relPath, SkipTooLarge)
+			continue
+		}
+		if vfs.totalSize+size > vfs.options.MaxTotalSize {
+			vfs.logger().Warn("stopping file loading: total size limit reached",
+				"max_mb", vfs.options.MaxTotalSize/(1024*1024))
+			vfs.skipFile(relPath, SkipTotalLimit)
+			break
+		}
+
+		// The header sizes are only a hint; the read itself is capped too
+		data, err := readZipEntry(f, password, vfs.options.MaxFileSize)
+		if errors.Is(err, ErrArchivePasswordRequired) || errors.Is(err, ErrArchivePasswordWrong) {
+			return fmt.Errorf("%s: %w", relPath, err)
+		} else if err != nil {
+			vfs.logger().Warn("skipping archive entry", "path", relPath, "error", err)
+			vfs.skipFile(relPath, SkipUnreadable+": "+err.Error())
+			continue
+		}
+
+		if err := vfs.storeFile(relPath, data, f.Modified); errors.Is(err, errMimeTypeBlocked) {
+			vfs.logger().Warn("skipping file: MIME type not allowed", "path", relPath)
+			vfs.skipFile(relPath, SkipMimeType)
+		} else if errors.Is(err, errManifestMismatch) {
+			vfs.logger().Warn("skipping file: does not match the manifest", "path", relPath)
+			vfs.skipFile(relPath, SkipManifest)
+		} else if err != nil {
+			vfs.logger().Warn("skipping file: encryption failed", "path", relPath, "error", err)
+			vfs.skipFile(relPath, SkipEncryption+": "+err.Error())
+		}
+		zero(data)
+	}
+	if vfs.excluded > 0 {
+		vfs.logger().Info("skipped entries matched by ignore rules", "count", vfs.excluded)
+	}
+	vfs.reportManifestMissing()
+	return nil
+}
+
+// skipArchivePath applies the hidden file and Exclude rules to an archive
+// entry and each folder above it, as loadFolder does while walking. It
+// returns the SkipInfo reason, or "" if the entry is loaded.
+func (vfs *VirtualFileSystem) skipArchivePath(relPath string) string {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, part := range parts {
+		if IsHidden(part) && !vfs.options.IncludeHidden {
+			return SkipHidden
+		}
+		if vfs.filter.Excluded(strings.Join(parts[:i+1], "/"), i < len(parts)-1) {
+			vfs.excluded++
+			return SkipExcluded
+		}
+	}
+	return ""
+}
+
+// ArchiveEntry streams one file out of a zip archive. Close it to release
+// the archive.
+type ArchiveEntry struct {
+	Name     string    // Slash-separated path inside the archive
+	Size     int64     // Uncompressed size from the central directory
+	ModTime  time.Time // Modification time recorded in the archive
+	MimeType string    // MIME type from the entry's extension ("" if unknown)
+
+	entry   io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+// OpenArchiveEntry opens a single entry of the zip archive at zipPath
+// without extracting the rest. The entry is found through the central
+// directory and decompressed as it is read, so memory stays flat however
+// large the archive is. Entry names go through the same checks as
+// ValidatePath; the archive's checksum is verified when the entry is read
+// to the end.
+func OpenArchiveEntry(zipPath, entry string) (*ArchiveEntry, error) {
+	cleaned, _, err := checkPath(entry)
+	if err != nil {
+		return nil, fmt.Errorf("archive entry %q: %w", entry, err)
+	}
+	name := path.Clean(filepath.ToSlash(cleaned))
+
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	f, err := archive.Open(name)
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("archive entry %q: %w", entry, err)
+	}
+	info, err := f.Stat()
+	if err == nil {
+		if info.IsDir() {
+			err = errors.New("is a directory")
+		} else if fh, ok := info.Sys().(*zip.FileHeader); ok && fh.Flags&zipFlagEncrypted != 0 {
+			err = errors.New("encrypted entries are only read by NewVirtualFileSystemFromArchive")
+		}
+	}
+	if err != nil {
+		f.Close()
+		archive.Close()
+		return nil, fmt.Errorf("archive entry %q: %w", entry, err)
+	}
+	return &ArchiveEntry{
+		Name:     name,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		MimeType: MimeTypeByExtension(name, nil),
+		entry:    f,
+		archive:  archive,
+	}, nil
+}
+
+// Read decompresses the next bytes of the entry
+func (e *ArchiveEntry) Read(p []byte) (int, error) {
+	return e.entry.Read(p)
+}
+
+// Close closes the entry and the archive
+func (e *ArchiveEntry) Close() error {
+	return errors.Join(e.entry.Close(), e.archive.Close())
+}
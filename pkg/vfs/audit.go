@@ -0,0 +1,65 @@
+package vfs
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Audit events written to Options.AuditLogWriter, in their "event" field
+const (
+	AuditEventIncident = "incident" // A security incident, with the fields of NewIncident
+	AuditEventAccess   = "access"   // A file read or stat: path, ip and success
+)
+
+// auditMu serializes audit writes from every VFS and preview server, so
+// lines sharing a writer never interleave
+var auditMu sync.Mutex
+
+// WriteAudit writes record to w as one line of JSON, with "event" set. A
+// nil w is a no-op. Rotating and flushing w are up to the caller.
+func WriteAudit(w io.Writer, event string, record map[string]any) error {
+	if w == nil {
+		return nil
+	}
+	out := make(map[string]any, len(record)+1)
+	for k, v := range record {
+		out[k] = v
+	}
+	out["event"] = event
+	line, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	_, err = w.Write(line)
+	return err
+}
+
+// auditAccess writes a file access to the audit log, with the path redacted
+// like incident paths
+func (vfs *VirtualFileSystem) auditAccess(path, ipAddr string, success bool) {
+	if vfs.options.AuditLogWriter == nil {
+		return
+	}
+	if !vfs.options.IncidentFullPaths {
+		path = RedactPath(vfs.options.RedactPaths, path)
+	}
+	vfs.writeAudit(AuditEventAccess, map[string]any{
+		"timestamp": time.Now().Unix(),
+		"path":      path,
+		"ip":        ipAddr,
+		"success":   success,
+	})
+}
+
+// writeAudit writes to Options.AuditLogWriter, logging failures
+func (vfs *VirtualFileSystem) writeAudit(event string, record map[string]any) {
+	if err := WriteAudit(vfs.options.AuditLogWriter, event, record); err != nil {
+		vfs.logger().Warn("write audit log", "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package vfs
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultBanDuration is how long Options.AutoBanAfter bans a client when
+// Options.BanDuration is unset
+const DefaultBanDuration = 10 * time.Minute
+
+// ErrIPBanned is returned (wrapped, and matching ErrAccessDenied) while a
+// client is banned; BanExpiry tells when the ban ends
+var ErrIPBanned = fmt.Errorf("%w: client temporarily banned", ErrAccessDenied)
+
+// ipStrikes counts a client's reads that left a file over the anomaly
+// threshold. The count starts over once it has been quiet for a ban
+// duration.
+type ipStrikes struct {
+	count int
+	last  time.Time
+}
+
+func (vfs *VirtualFileSystem) banDuration() time.Duration {
+	if vfs.options.BanDuration <= 0 {
+		return DefaultBanDuration
+	}
+	return vfs.options.BanDuration
+}
+
+// checkBan refuses requests from a banned client. Anonymous callers are
+// never banned. Refused requests are not counted, so they don't extend the
+// ban.
+func (vfs *VirtualFileSystem) checkBan(ipAddr string) error {
+	if vfs.options.AutoBanAfter <= 0 || anonymousCaller(ipAddr) {
+		return nil
+	}
+	if _, banned := vfs.BanExpiry(ipAddr); banned {
+		return ErrIPBanned
+	}
+	return nil
+}
+
+// BanExpiry returns when the ban on a client ends, and false if it is not
+// banned. ipAddr may be given raw or as logged (already anonymized).
+func (vfs *VirtualFileSystem) BanExpiry(ipAddr string) (time.Time, bool) {
+	ipAddr = vfs.AnonymizeIP(ipAddr)
+	vfs.accessMu.RLock()
+	defer vfs.accessMu.RUnlock()
+	until, ok := vfs.ipBans[ipAddr]
+	if !ok || !time.Now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// strikeIP records that a read from ipAddr left a file over the anomaly
+// threshold and bans the client at Options.AutoBanAfter strikes. Called
+// with accessMu held.
+func (vfs *VirtualFileSystem) strikeIP(ipAddr string) {
+	if vfs.options.AutoBanAfter <= 0 || ipAddr == "" || vfs.ipStrikes == nil || vfs.ipBans == nil {
+		return
+	}
+	now := time.Now()
+	strikes, tracked := vfs.ipStrikes[ipAddr]
+	if !tracked && len(vfs.ipStrikes) >= maxTrackedClients {
+		return
+	}
+	if now.Sub(strikes.last) > vfs.banDuration() {
+		strikes.count = 0
+	}
+	strikes.count++
+	strikes.last = now
+	vfs.ipStrikes[ipAddr] = strikes
+	if strikes.count < vfs.options.AutoBanAfter {
+		return
+	}
+
+	until := now.Add(vfs.banDuration())
+	vfs.ipBans[ipAddr] = until
+	delete(vfs.ipStrikes, ipAddr)
+	vfs.logSecurityIncident("ip_banned", "high", "Client banned after repeated anomalous reads", map[string]any{
+		"ip":           ipAddr,
+		"strikes":      strikes.count,
+		"banned_until": until,
+	})
+}
+
+// pruneBans drops expired bans and strike counts that have decayed. Called
+// with accessMu held.
+func (vfs *VirtualFileSystem) pruneBans(now time.Time) {
+	for ip, until := range vfs.ipBans {
+		if !now.Before(until) {
+			delete(vfs.ipBans, ip)
+		}
+	}
+	for ip, strikes := range vfs.ipStrikes {
+		if now.Sub(strikes.last) > vfs.banDuration() {
+			delete(vfs.ipStrikes, ip)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package vfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressDataRejectsBomb(t *testing.T) {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	fs, err := NewVirtualFileSystemWithOptions(t.TempDir(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	// 64 MB of zeros compress to about 64 KB but claim to be 100 bytes
+	bomb := gzipBytes(t, make([]byte, 64<<20))
+	if _, err := fs.decompressData(bomb, 100); !errors.Is(err, ErrDecompressionLimit) {
+		t.Fatalf("decompressData(bomb) error = %v, want ErrDecompressionLimit", err)
+	}
+
+	// Within the margin is fine
+	text := bytes.Repeat([]byte("previewer "), 1000)
+	out, err := fs.decompressData(gzipBytes(t, text), int64(len(text)))
+	if err != nil {
+		t.Fatalf("decompressData: %v", err)
+	}
+	if !bytes.Equal(out, text) {
+		t.Fatalf("decompressData returned %d bytes, want the original %d", len(out), len(text))
+	}
+	// Sized from the recorded size, not grown while reading
+	if cap(out) != len(text)+1 {
+		t.Fatalf("decompressData buffer capacity %d, want %d", cap(out), len(text)+1)
+	}
+}
+
+func TestDigestGzipBounded(t *testing.T) {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	fs, err := NewVirtualFileSystemWithOptions(t.TempDir(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	bomb := gzipBytes(t, make([]byte, 64<<20))
+	if _, _, err := fs.digestGzip(t.Context(), bomb, 100); !errors.Is(err, ErrDecompressionLimit) {
+		t.Fatalf("digestGzip(bomb) error = %v, want ErrDecompressionLimit", err)
+	}
+
+	text := bytes.Repeat([]byte("previewer "), 1000)
+	stream := gzipBytes(t, text)
+	if _, _, err := fs.digestGzip(t.Context(), stream, int64(len(text))); err != nil {
+		t.Fatalf("digestGzip: %v", err)
+	}
+	// Shorter than recorded, within the margin
+	if _, _, err := fs.digestGzip(t.Context(), stream, int64(len(text))+10); err == nil {
+		t.Fatal("digestGzip accepted a stream shorter than the recorded size")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, _, err := fs.digestGzip(ctx, stream, int64(len(text))); !errors.Is(err, context.Canceled) {
+		t.Fatalf("digestGzip with a cancelled context: %v, want context.Canceled", err)
+	}
+}
+
+func TestValidCompressionLevel(t *testing.T) {
+	for level := -3; level <= 10; level++ {
+		err := validCompressionLevel(level)
+		if want := level >= 0 && level <= gzip.BestCompression; (err == nil) != want {
+			t.Errorf("validCompressionLevel(%d) = %v, want valid %v", level, err, want)
+		}
+	}
+}
+
+func TestSmallMaxFileSize(t *testing.T) {
+	// Smaller than the self-test vector, which must still decompress
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.MaxFileSize = 1024
+	fs, err := NewVirtualFileSystemWithOptions(t.TempDir(), options)
+	if err != nil {
+		t.Fatalf("NewVirtualFileSystemWithOptions with a 1 KB MaxFileSize: %v", err)
+	}
+	fs.SecureCleanup()
+}
@@ -0,0 +1,98 @@
+package vfs
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// Reasons recorded in SkipInfo. Unreadable and encryption skips carry the
+// error after a colon.
+const (
+	SkipHidden     = "hidden"
+	SkipExcluded   = "excluded"
+	SkipTooLarge   = "exceeds max file size"
+	SkipTotalLimit = "total size limit reached"
+	SkipMimeType   = "MIME type not allowed"
+	SkipManifest   = "does not match the manifest"
+	SkipUnreadable = "unreadable"
+	SkipEncryption = "encryption failed"
+)
+
+// SkipInfo is an entry of the source folder that was not loaded
+type SkipInfo struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// DryRunReport lists what loading a folder with the given options would do
+type DryRunReport struct {
+	Loaded    []FileInfo // Files that would be loaded, sorted by path
+	Skipped   []SkipInfo // Entries that would be left out, in walk order
+	TotalSize int64      // Bytes the loaded files add up to
+}
+
+// skipFile records an entry the current load left out
+func (vfs *VirtualFileSystem) skipFile(relPath, reason string) {
+	vfs.skipped = append(vfs.skipped, SkipInfo{Path: relPath, Reason: reason})
+}
+
+// SkippedFiles returns the entries of the source folder, archive or map that
+// the last load or Reload left out, and why, in walk order. Hidden and excluded
+// folders are listed once, not file by file.
+func (vfs *VirtualFileSystem) SkippedFiles() []SkipInfo {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	return append([]SkipInfo(nil), vfs.skipped...)
+}
+
+// DryRun walks folderPath with the same filters, size limits, MIME rules
+// and manifest as NewVirtualFileSystemWithOptions and reports which files
+// would be loaded and which would be skipped. Nothing is encrypted or kept
+// in memory, and no keys are generated.
+func DryRun(folderPath string, options Options) (*DryRunReport, error) {
+	if options.Silent {
+		options.Logger = slog.New(slog.DiscardHandler)
+	}
+	if options.Logger == nil {
+		options.Logger = defaultLogger(options.Verbose)
+	}
+	if err := validRedactMode(options.RedactPaths); err != nil {
+		return nil, err
+	}
+	options.Logger = RedactingLogger(options.Logger, options.RedactPaths)
+	manifest, err := newManifest(options.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	options.MasterKey = nil
+	options.Passphrase = ""
+	options.ArchivePassword = ""
+
+	vfs := &VirtualFileSystem{
+		rootPath: folderPath,
+		files:    make(map[string]*VirtualFile),
+		manifest: manifest,
+		options:  options,
+		dryRun:   true,
+
+		logCallback: instanceCallback(options.LogCallback),
+	}
+	if err := vfs.loadRoot(); err != nil {
+		return nil, fmt.Errorf("dry run: %w", err)
+	}
+
+	report := &DryRunReport{Skipped: vfs.skipped, TotalSize: vfs.totalSize}
+	for _, vfile := range vfs.files {
+		report.Loaded = append(report.Loaded, FileInfo{
+			Path:     vfile.Path,
+			Name:     vfile.Name,
+			Size:     vfile.Size,
+			MimeType: vfile.MimeType,
+			Hash:     vfile.Hash,
+			ModTime:  vfile.ModTime,
+		})
+	}
+	sort.Slice(report.Loaded, func(i, j int) bool { return report.Loaded[i].Path < report.Loaded[j].Path })
+	return report, nil
+}
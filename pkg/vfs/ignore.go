@@ -0,0 +1,155 @@
+package vfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName is the optional ignore file read from the folder root
+const IgnoreFileName = ".previewerignore"
+
+// PathFilter decides which entries of a folder are left out, using
+// gitignore-style patterns from Options.Exclude and the folder's
+// .previewerignore. Both sets apply; later patterns override earlier ones
+// and "!pattern" re-includes.
+type PathFilter struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// NewPathFilter builds the filter for root from the explicit exclude patterns
+// followed by the lines of root/.previewerignore, if present
+func NewPathFilter(root string, exclude []string) (*PathFilter, error) {
+	f := &PathFilter{}
+	for _, pattern := range exclude {
+		if err := f.add(pattern); err != nil {
+			return nil, fmt.Errorf("exclude pattern %q: %w", pattern, err)
+		}
+	}
+	if root == "" {
+		return f, nil
+	}
+
+	file, err := os.Open(filepath.Join(root, IgnoreFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if err := f.add(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", IgnoreFileName, line, err)
+		}
+	}
+	return f, scanner.Err()
+}
+
+// add parses one gitignore-style line
+func (f *PathFilter) add(line string) error {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	rule := ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+
+	// A slash anywhere but the end anchors the pattern to the root;
+	// otherwise it matches a name at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(line[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return err
+	}
+	rule.re = re
+	f.rules = append(f.rules, rule)
+	return nil
+}
+
+// Excluded reports whether the entry at relPath (relative to the root, using
+// either separator) is filtered out. Excluding a folder excludes everything
+// in it, since the walk does not descend into it.
+func (f *PathFilter) Excluded(relPath string, isDir bool) bool {
+	if f == nil || len(f.rules) == 0 {
+		return false
+	}
+	relPath = strings.TrimPrefix(filepath.ToSlash(relPath), "/")
+	excluded := false
+	for _, rule := range f.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(relPath) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
@@ -0,0 +1,110 @@
+package vfs
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ipRules holds the parsed Options.AllowedIPs and Options.BlockedIPs
+type ipRules struct {
+	allowed []netip.Prefix
+	blocked []netip.Prefix
+}
+
+// newIPRules parses the IP lists of the options. Entries are addresses
+// ("10.0.0.5", "::1") or CIDR ranges ("10.0.0.0/8").
+func newIPRules(options Options) (ipRules, error) {
+	allowed, err := parsePrefixes(options.AllowedIPs)
+	if err != nil {
+		return ipRules{}, fmt.Errorf("AllowedIPs: %w", err)
+	}
+	blocked, err := parsePrefixes(options.BlockedIPs)
+	if err != nil {
+		return ipRules{}, fmt.Errorf("BlockedIPs: %w", err)
+	}
+	return ipRules{allowed: allowed, blocked: blocked}, nil
+}
+
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// parseClientAddr reads a client IP as passed to the VFS: a bare address or
+// host:port. Lists such as a raw X-Forwarded-For value are not addresses;
+// their entries can be chosen by the client.
+func parseClientAddr(ip string) (netip.Addr, bool) {
+	ip = strings.TrimSpace(ip)
+	if addrPort, err := netip.ParseAddrPort(ip); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIPRules reports whether Options.AllowedIPs or Options.BlockedIPs is set
+func (vfs *VirtualFileSystem) HasIPRules() bool {
+	return len(vfs.ipRules.allowed) > 0 || len(vfs.ipRules.blocked) > 0
+}
+
+// IPAllowed applies Options.BlockedIPs, then Options.AllowedIPs when it is
+// non-empty, to a raw client address. Anonymous callers (no IP, see
+// anonymousCaller) are allowed. Identifiers that are not addresses, anonymized IPs included,
+// cannot be matched against the rules and are refused while any are set;
+// pass the raw address, the VFS anonymizes it afterwards.
+func (vfs *VirtualFileSystem) IPAllowed(ip string) bool {
+	if !vfs.HasIPRules() || anonymousCaller(ip) {
+		return true
+	}
+	addr, ok := parseClientAddr(ip)
+	if !ok {
+		return false
+	}
+	if containsAddr(vfs.ipRules.blocked, addr) {
+		return false
+	}
+	return len(vfs.ipRules.allowed) == 0 || containsAddr(vfs.ipRules.allowed, addr)
+}
+
+// checkIP refuses reads from clients outside the IP rules, logging an
+// incident for each
+func (vfs *VirtualFileSystem) checkIP(path, ipAddr string) error {
+	if vfs.IPAllowed(ipAddr) {
+		return nil
+	}
+	vfs.logSecurityIncident("ip_blocked", "high", "Read from a blocked IP refused", map[string]any{
+		"path": path,
+		"ip":   vfs.AnonymizeIP(ipAddr),
+	})
+	return fmt.Errorf("%w: client IP not allowed", ErrAccessDenied)
+}
@@ -0,0 +1,172 @@
+package vfs
+
+import (
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+const keySaltSize = 16
+const minMasterKeySize = 32
+const passphraseIterations = 600000 // PBKDF2-HMAC-SHA256, per current OWASP guidance
+
+// HKDF info labels, so the two keys are independent
+const encryptionKeyInfo = "previewer vfs encryption key"
+const hmacKeyInfo = "previewer vfs hmac key"
+
+// randomKeys generates fresh encryption and HMAC keys
+func randomKeys() (encryptionKey, hmacKey []byte, err error) {
+	encryptionKey = make([]byte, encryptionKeySize)
+	hmacKey = make([]byte, encryptionKeySize)
+
+	if _, err := io.ReadFull(rand.Reader, encryptionKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	if _, err := io.ReadFull(rand.Reader, hmacKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate HMAC key: %w", err)
+	}
+	return encryptionKey, hmacKey, nil
+}
+
+// initialKeys returns random keys, or keys derived deterministically from
+// Options.MasterKey or Options.Passphrase and the salt. The same secret and
+// salt always produce the same keys, so an encrypted VFS can be reopened.
+func initialKeys(options Options) (encryptionKey, hmacKey, salt []byte, err error) {
+	if len(options.MasterKey) == 0 && options.Passphrase == "" {
+		encryptionKey, hmacKey, err = randomKeys()
+		return encryptionKey, hmacKey, nil, err
+	}
+	if len(options.MasterKey) > 0 && options.Passphrase != "" {
+		return nil, nil, nil, fmt.Errorf("MasterKey and Passphrase are mutually exclusive")
+	}
+	if len(options.MasterKey) > 0 && len(options.MasterKey) < minMasterKeySize {
+		return nil, nil, nil, fmt.Errorf("MasterKey must be at least %d bytes", minMasterKeySize)
+	}
+
+	salt = append([]byte(nil), options.KeySalt...)
+	if len(salt) == 0 {
+		salt = make([]byte, keySaltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to generate key salt: %w", err)
+		}
+	}
+
+	secret := options.MasterKey
+	if options.Passphrase != "" {
+		secret, err = pbkdf2.Key(sha256.New, options.Passphrase, salt, passphraseIterations, encryptionKeySize)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to derive key from passphrase: %w", err)
+		}
+		defer zero(secret)
+	}
+
+	if encryptionKey, err = hkdf.Key(sha256.New, secret, salt, encryptionKeyInfo, encryptionKeySize); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	if hmacKey, err = hkdf.Key(sha256.New, secret, salt, hmacKeyInfo, encryptionKeySize); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to derive HMAC key: %w", err)
+	}
+	return encryptionKey, hmacKey, salt, nil
+}
+
+// KeySalt returns the salt the keys were derived with, to be stored
+// alongside anything encrypted with this VFS and passed back as
+// Options.KeySalt. It is nil when the keys are random.
+func (vfs *VirtualFileSystem) KeySalt() []byte {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	return append([]byte(nil), vfs.keySalt...)
+}
+
+// RotateKeys generates new encryption and HMAC keys, re-encrypts every stored
+// file and recomputes its HMAC under the new keys, then zeroes the old keys.
+// Every file is verified before anything is replaced; if one fails, no key is
+// rotated and an error is returned. Reads block for the duration of the
+// rotation and never observe a half-rotated file; a Reload in progress is
+// waited for.
+//
+// The new keys are always random: a VFS whose keys were derived from a
+// MasterKey or Passphrase can no longer be reproduced from it afterwards,
+// and KeySalt returns nil.
+func (vfs *VirtualFileSystem) RotateKeys() error {
+	encryptionKey, hmacKey, err := randomKeys()
+	if err != nil {
+		return err
+	}
+	next := &VirtualFileSystem{
+		encryptionKey: encryptionKey,
+		hmacKey:       hmacKey,
+		options:       vfs.options,
+	}
+
+	vfs.rekeyMu.Lock()
+	defer vfs.rekeyMu.Unlock()
+	vfs.mu.Lock()
+	defer vfs.mu.Unlock()
+
+	if vfs.files == nil {
+		return fmt.Errorf("VFS has been cleaned up")
+	}
+
+	type rekeyed struct {
+		data []byte
+		hmac string
+	}
+	updates := make(map[string]rekeyed, len(vfs.files))
+	for path, vfile := range vfs.files {
+		stored, err := vfs.decryptData(vfile.Data)
+		if err != nil {
+			return fmt.Errorf("rotate keys: %s: %w", path, err)
+		}
+		plaintext := stored
+		if vfile.isCompressed {
+			if plaintext, err = vfs.decompressData(stored, vfile.Size); err != nil {
+				return fmt.Errorf("rotate keys: %s: %w", path, err)
+			}
+		}
+		if !vfs.verifyHMAC(plaintext, vfile.HMAC) {
+			vfs.logSecurityIncident("tampering", "critical", "HMAC verification failed during key rotation", map[string]any{
+				"path": path,
+			})
+			return fmt.Errorf("rotate keys: %s: HMAC verification failed", path)
+		}
+
+		encrypted, err := next.encryptData(stored)
+		if err != nil {
+			return fmt.Errorf("rotate keys: %s: %w", path, err)
+		}
+		updates[path] = rekeyed{data: encrypted, hmac: next.calculateHMAC(plaintext)}
+		if vfs.searchIndex != nil && isSearchable(vfile.MimeType) {
+			next.indexFile(path, plaintext)
+		}
+		zero(stored)
+		zero(plaintext)
+	}
+
+	for path, vfile := range vfs.files {
+		zero(vfile.Data)
+		vfile.Data = updates[path].data
+		vfile.HMAC = updates[path].hmac
+	}
+	if vfs.searchIndex != nil {
+		vfs.searchIndex = next.searchIndex
+	}
+	zero(vfs.encryptionKey)
+	zero(vfs.hmacKey)
+	vfs.encryptionKey = next.encryptionKey
+	vfs.hmacKey = next.hmacKey
+	vfs.keySalt = nil
+
+	vfs.logger().Info("VFS keys rotated", "files", len(vfs.files))
+	return nil
+}
+
+// zero overwrites b with zeros
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
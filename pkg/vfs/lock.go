@@ -0,0 +1,140 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultLockDuration is how long a file or client stays locked once
+// Options.LockAfterFailures is reached, when Options.LockDuration is unset
+const DefaultLockDuration = 15 * time.Minute
+
+// ErrAccessDenied is returned (wrapped) for reads and stats refused by the
+// VFS's access checks, including lockouts
+var ErrAccessDenied = errors.New("access denied")
+
+// Anonymous callers
+//
+// An empty IP marks an in-process caller with no client behind it, as with
+// ReadFile, Bytes and Stat. Such reads are exempt from everything
+// keyed by client (IP rules, bans, client locks) but not from file locks,
+// rate limits or permissions. Anything serving a remote client must pass its
+// address; the preview server always does (see file.previewServer.requestIP).
+
+// anonymousCaller reports whether ipAddr marks an in-process caller
+func anonymousCaller(ipAddr string) bool {
+	return ipAddr == ""
+}
+
+func (vfs *VirtualFileSystem) lockDuration() time.Duration {
+	if vfs.options.LockDuration <= 0 {
+		return DefaultLockDuration
+	}
+	return vfs.options.LockDuration
+}
+
+// checkLockout refuses a request for a locked file or from a locked client.
+// Anonymous callers are only refused locked files. Refused requests are not
+// counted, so they don't extend the lock.
+func (vfs *VirtualFileSystem) checkLockout(path, ipAddr string) error {
+	if vfs.options.LockAfterFailures <= 0 {
+		return nil
+	}
+	now := time.Now()
+	vfs.accessMu.RLock()
+	defer vfs.accessMu.RUnlock()
+	if record, ok := vfs.accessLog[path]; ok && now.Before(record.LockedUntil) {
+		return fmt.Errorf("%w: file locked after repeated failed accesses", ErrAccessDenied)
+	}
+	if anonymousCaller(ipAddr) {
+		return nil
+	}
+	if until, ok := vfs.lockedIPs[ipAddr]; ok && now.Before(until) {
+		return fmt.Errorf("%w: client locked after repeated failed accesses", ErrAccessDenied)
+	}
+	return nil
+}
+
+// lockFileOnFailure locks a file for the cooldown every LockAfterFailures
+// failed accesses. Called with accessMu held.
+func (vfs *VirtualFileSystem) lockFileOnFailure(record *FileAccessRecord) {
+	n := vfs.options.LockAfterFailures
+	if n <= 0 || record.FailedAttempts%n != 0 {
+		return
+	}
+	record.LockedUntil = time.Now().Add(vfs.lockDuration())
+	record.SuspiciousFlags = append(record.SuspiciousFlags, "locked")
+	vfs.logSecurityIncident("file_locked", "high", "File locked after repeated failed accesses", map[string]any{
+		"path":            record.Path,
+		"failed_attempts": record.FailedAttempts,
+		"locked_until":    record.LockedUntil,
+	})
+}
+
+// lockClientOnFailure locks a client for the cooldown every
+// LockAfterFailures requests for invalid or unknown paths. Called with
+// accessMu held.
+func (vfs *VirtualFileSystem) lockClientOnFailure(ipAddr string, count int) {
+	n := vfs.options.LockAfterFailures
+	if n <= 0 || count%n != 0 || ipAddr == "unknown" || vfs.lockedIPs == nil {
+		return
+	}
+	until := time.Now().Add(vfs.lockDuration())
+	vfs.lockedIPs[ipAddr] = until
+	vfs.logSecurityIncident("client_locked", "high", "Client locked after repeated requests for invalid paths", map[string]any{
+		"ip":              ipAddr,
+		"failed_attempts": count,
+		"locked_until":    until,
+	})
+}
+
+// Unlock lifts the lock on a file before its cooldown ends. It reports
+// whether the file was locked.
+func (vfs *VirtualFileSystem) Unlock(path string) bool {
+	vfs.accessMu.Lock()
+	defer vfs.accessMu.Unlock()
+	record, ok := vfs.accessLog[normalizePath(path)]
+	if !ok || !time.Now().Before(record.LockedUntil) {
+		return false
+	}
+	record.LockedUntil = time.Time{}
+	vfs.logger().Info("file unlocked", "path", record.Path)
+	return true
+}
+
+// UnlockIP lifts the lock or ban on a client before it ends. ipAddr may be
+// given as logged, i.e. already anonymized. It reports whether the client
+// was locked or banned.
+func (vfs *VirtualFileSystem) UnlockIP(ipAddr string) bool {
+	ipAddr = vfs.AnonymizeIP(ipAddr)
+	vfs.accessMu.Lock()
+	defer vfs.accessMu.Unlock()
+	until, ok := vfs.lockedIPs[ipAddr]
+	if ban, banned := vfs.ipBans[ipAddr]; banned && ban.After(until) {
+		until, ok = ban, true
+	}
+	delete(vfs.lockedIPs, ipAddr)
+	delete(vfs.ipBans, ipAddr)
+	if !ok || !time.Now().Before(until) {
+		return false
+	}
+	vfs.logger().Info("client unlocked", "ip", ipAddr)
+	return true
+}
+
+// lockCounts returns the number of files and clients currently locked.
+// Called with accessMu held.
+func (vfs *VirtualFileSystem) lockCounts(now time.Time) (files, clients int) {
+	for _, record := range vfs.accessLog {
+		if now.Before(record.LockedUntil) {
+			files++
+		}
+	}
+	for _, until := range vfs.lockedIPs {
+		if now.Before(until) {
+			clients++
+		}
+	}
+	return files, clients
+}
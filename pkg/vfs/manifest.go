@@ -0,0 +1,117 @@
+package vfs
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errManifestMismatch is returned by storeFile for files whose content does
+// not match the SHA-256 recorded in Options.Manifest
+var errManifestMismatch = errors.New("content does not match the manifest")
+
+// newManifest validates Options.Manifest and normalizes its paths the way
+// the VFS keys its files
+func newManifest(entries map[string]string) (map[string]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	manifest := make(map[string]string, len(entries))
+	for p, sum := range entries {
+		sum = strings.ToLower(strings.TrimSpace(sum))
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("Manifest: %q: not a hex SHA-256 digest", p)
+		}
+		cleaned := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+		if cleaned == "" {
+			return nil, fmt.Errorf("Manifest: %q: invalid path", p)
+		}
+		manifest[cleaned] = sum
+	}
+	return manifest, nil
+}
+
+// ReadManifest reads a manifest in sha256sum format ("<digest>  <path>" per
+// line, "#" comments) for Options.Manifest
+func ReadManifest(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseManifest(f)
+}
+
+// ParseManifest parses sha256sum output into a path -> digest map
+func ParseManifest(r io.Reader) (map[string]string, error) {
+	manifest := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sum, p, ok := strings.Cut(text, " ")
+		// sha256sum marks binary-mode entries with "*"
+		p = strings.TrimPrefix(strings.TrimLeft(p, " "), "*")
+		if !ok || p == "" {
+			return nil, fmt.Errorf("manifest line %d: want \"<sha256>  <path>\"", line)
+		}
+		manifest[p] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// checkManifest compares a file being loaded against the manifest. Files the
+// manifest doesn't list are loaded but reported; mismatches are refused.
+func (vfs *VirtualFileSystem) checkManifest(relPath, hash string) error {
+	if vfs.manifest == nil {
+		return nil
+	}
+	key := filepath.ToSlash(relPath)
+	expected, ok := vfs.manifest[key]
+	if !ok {
+		vfs.logSecurityIncident("manifest_unlisted", "medium", "File not listed in the manifest", map[string]any{
+			"path": key,
+		})
+		return nil
+	}
+	if expected != hash {
+		vfs.logSecurityIncident("manifest_mismatch", "critical", "File content does not match the manifest", map[string]any{
+			"path":     key,
+			"expected": expected,
+			"actual":   hash,
+		})
+		return errManifestMismatch
+	}
+	return nil
+}
+
+// reportManifestMissing logs manifest entries that were not loaded, whether
+// absent on disk or skipped by size, type or ignore rules
+func (vfs *VirtualFileSystem) reportManifestMissing() {
+	var missing []string
+	for p := range vfs.manifest {
+		if _, ok := vfs.files[filepath.FromSlash(p)]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	sort.Strings(missing)
+	for _, p := range missing {
+		vfs.logger().Warn("manifest entry not loaded", "path", p)
+	}
+	vfs.logger().Warn("files listed in the manifest were not loaded", "count", len(missing))
+}
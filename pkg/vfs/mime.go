@@ -0,0 +1,90 @@
+package vfs
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// sourceMimeTypes covers source and config extensions that the system MIME
+// table often lacks (or, like .ts, maps to an unrelated media type)
+var sourceMimeTypes = map[string]string{
+	".go":         "text/x-go",
+	".mod":        "text/plain; charset=utf-8",
+	".sum":        "text/plain; charset=utf-8",
+	".rs":         "text/x-rust",
+	".ts":         "text/x-typescript",
+	".tsx":        "text/x-typescript",
+	".jsx":        "text/javascript; charset=utf-8",
+	".mjs":        "text/javascript; charset=utf-8",
+	".cjs":        "text/javascript; charset=utf-8",
+	".py":         "text/x-python",
+	".rb":         "text/x-ruby",
+	".php":        "text/x-php",
+	".java":       "text/x-java",
+	".kt":         "text/x-kotlin",
+	".swift":      "text/x-swift",
+	".c":          "text/x-c",
+	".h":          "text/x-c",
+	".cpp":        "text/x-c++",
+	".cc":         "text/x-c++",
+	".hpp":        "text/x-c++",
+	".cs":         "text/x-csharp",
+	".sh":         "text/x-shellscript",
+	".bash":       "text/x-shellscript",
+	".zsh":        "text/x-shellscript",
+	".sql":        "text/x-sql",
+	".md":         "text/markdown; charset=utf-8",
+	".markdown":   "text/markdown; charset=utf-8",
+	".yaml":       "text/yaml; charset=utf-8",
+	".yml":        "text/yaml; charset=utf-8",
+	".toml":       "text/x-toml",
+	".ini":        "text/plain; charset=utf-8",
+	".cfg":        "text/plain; charset=utf-8",
+	".conf":       "text/plain; charset=utf-8",
+	".env":        "text/plain; charset=utf-8",
+	".log":        "text/plain; charset=utf-8",
+	".proto":      "text/plain; charset=utf-8",
+	".graphql":    "text/plain; charset=utf-8",
+	".dockerfile": "text/plain; charset=utf-8",
+	".scss":       "text/x-scss",
+	".less":       "text/x-less",
+	".vue":        "text/plain; charset=utf-8",
+	".svelte":     "text/plain; charset=utf-8",
+}
+
+// officeMimeTypes covers office documents, so they reach a
+// render.DocumentConverter even where the system MIME table is missing
+var officeMimeTypes = map[string]string{
+	".doc":  "application/msword",
+	".xls":  "application/vnd.ms-excel",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".rtf":  "application/rtf",
+}
+
+// MimeTypeByExtension returns the MIME type for a file name's extension.
+// Lookups try extra (typically Options.MimeTypes) first, then the built-in
+// tables of source/config and office types, then the system MIME table. It returns ""
+// when the extension is unknown.
+func MimeTypeByExtension(name string, extra map[string]string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if mimeType, ok := extra[ext]; ok {
+		return mimeType
+	}
+	if mimeType, ok := sourceMimeTypes[ext]; ok {
+		return mimeType
+	}
+	if mimeType, ok := officeMimeTypes[ext]; ok {
+		return mimeType
+	}
+	return mime.TypeByExtension(ext)
+}
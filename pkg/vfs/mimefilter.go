@@ -0,0 +1,41 @@
+package vfs
+
+import (
+	"errors"
+	"mime"
+	"strings"
+)
+
+// errMimeTypeBlocked is returned by storeFile for types excluded by
+// Options.AllowedMimeTypes or Options.BlockedMimeTypes
+var errMimeTypeBlocked = errors.New("MIME type not allowed")
+
+// mimeTypeMatches reports whether mimeType matches any pattern. Patterns are
+// full types ("application/pdf") or a major type wildcard ("image/*");
+// parameters such as charset are ignored.
+func mimeTypeMatches(mimeType string, patterns []string) bool {
+	if base, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = base
+	}
+	mimeType = strings.ToLower(mimeType)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if major, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mimeType, major+"/") {
+				return true
+			}
+		} else if mimeType == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// mimeTypeAllowed applies Options.BlockedMimeTypes, then
+// Options.AllowedMimeTypes when it is non-empty
+func (vfs *VirtualFileSystem) mimeTypeAllowed(mimeType string) bool {
+	if mimeTypeMatches(mimeType, vfs.options.BlockedMimeTypes) {
+		return false
+	}
+	return len(vfs.options.AllowedMimeTypes) == 0 || mimeTypeMatches(mimeType, vfs.options.AllowedMimeTypes)
+}
@@ -0,0 +1,80 @@
+package vfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/oarkflow/previewer/pkg/acl"
+)
+
+// PermissionsFileName is the optional sidecar read from the folder root. It
+// maps paths relative to the root to acl.ItemPermissions, e.g.
+//
+//	{"reports/q3.pdf": {"canRead": true}, "secrets": {"canRead": false}}
+//
+// A folder's entry applies to everything below it unless a deeper entry
+// overrides it; paths not covered get the default (read only). Files that
+// deny reading are still loaded but refused by ReadFile and Search, and left
+// out of the search index and of the folder tree (a folder that denies
+// reading is left out with everything in it). The sidecar itself is never
+// loaded.
+const PermissionsFileName = ".permissions.json"
+
+// defaultPermissions is what files get when the sidecar doesn't cover them
+var defaultPermissions = acl.ItemPermissions{CanRead: true}
+
+// readPermissions loads root/.permissions.json, if present, with its paths
+// normalized the way the VFS keys its files
+func readPermissions(root string) (map[string]acl.ItemPermissions, error) {
+	data, err := os.ReadFile(filepath.Join(root, PermissionsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]acl.ItemPermissions
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", PermissionsFileName, err)
+	}
+	permissions := make(map[string]acl.ItemPermissions, len(entries))
+	for p, perms := range entries {
+		cleaned := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+		if cleaned == "" {
+			return nil, fmt.Errorf("%s: %q: invalid path", PermissionsFileName, p)
+		}
+		permissions[cleaned] = perms
+	}
+	return permissions, nil
+}
+
+// permissionsFor returns the permissions of relPath: the sidecar entry for
+// the path or its nearest listed parent folder, or the default
+func (vfs *VirtualFileSystem) permissionsFor(relPath string) acl.ItemPermissions {
+	if vfs.permissions == nil {
+		return defaultPermissions
+	}
+	p := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(relPath)), "/")
+	for p != "" && p != "." {
+		if perms, ok := vfs.permissions[p]; ok {
+			return perms
+		}
+		p = path.Dir(p)
+	}
+	return defaultPermissions
+}
+
+// Permissions returns the permissions the folder's .permissions.json gives
+// path, file or folder, or the default for paths it doesn't cover
+func (vfs *VirtualFileSystem) Permissions(path string) acl.ItemPermissions {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	return vfs.permissionsFor(path)
+}
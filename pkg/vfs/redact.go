@@ -0,0 +1,158 @@
+package vfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// Path redaction modes for Options.RedactPaths
+const (
+	RedactPathsBasename = "basename" // Log only the file name: "/patients/jane/report.pdf" -> "report.pdf"
+	RedactPathsHash     = "hash"     // Log a stable hash of the path: "sha256:1f2e3d4c5b6a"
+)
+
+const redactedHashPrefix = "sha256:"
+
+// redactedAttrKeys are the log attribute and incident detail keys that hold
+// file paths
+var redactedAttrKeys = map[string]bool{"path": true, "file": true, "folder": true}
+
+// validRedactMode reports whether mode is a known Options.RedactPaths value
+func validRedactMode(mode string) error {
+	switch mode {
+	case "", RedactPathsBasename, RedactPathsHash:
+		return nil
+	}
+	return fmt.Errorf("unknown path redaction mode %q (want %q or %q)", mode, RedactPathsBasename, RedactPathsHash)
+}
+
+// RedactPath returns p as it is logged under the given Options.RedactPaths
+// mode. The hash is unkeyed so the same path can be correlated across runs.
+// Redacting an already redacted path returns it unchanged.
+func RedactPath(mode, p string) string {
+	switch mode {
+	case RedactPathsBasename:
+		return path.Base(strings.ReplaceAll(p, "\\", "/"))
+	case RedactPathsHash:
+		if p == "" || strings.HasPrefix(p, redactedHashPrefix) {
+			return p
+		}
+		sum := sha256.Sum256([]byte(p))
+		return redactedHashPrefix + hex.EncodeToString(sum[:6])
+	default:
+		return p
+	}
+}
+
+// redactDetails returns a copy of incident details with the path values
+// redacted
+func redactDetails(mode string, details map[string]any) map[string]any {
+	if mode == "" || details == nil {
+		return details
+	}
+	out := make(map[string]any, len(details))
+	for k, v := range details {
+		if s, ok := v.(string); ok && redactedAttrKeys[k] {
+			v = RedactPath(mode, s)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// RedactIncident redacts the file paths in an incident's details, as done
+// for incidents when Options.RedactPaths is set and IncidentFullPaths is not
+func RedactIncident(mode string, data map[string]any) map[string]any {
+	details, ok := data["details"].(map[string]any)
+	if mode == "" || !ok {
+		return data
+	}
+	out := make(map[string]any, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	out["details"] = redactDetails(mode, details)
+	return out
+}
+
+// RedactingLogger wraps l so that "path", "file" and "folder" attributes of
+// records above debug level are redacted. Debug records, which are only
+// shown in verbose mode, keep full paths.
+func RedactingLogger(l *slog.Logger, mode string) *slog.Logger {
+	if mode == "" {
+		return l
+	}
+	if _, ok := l.Handler().(*redactingHandler); ok {
+		return l
+	}
+	return slog.New(&redactingHandler{next: l.Handler(), mode: mode})
+}
+
+type redactingHandler struct {
+	next slog.Handler
+	mode string
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level <= slog.LevelDebug {
+		return h.next.Handle(ctx, r)
+	}
+	// Paths also show up inside other attributes, typically errors such as
+	// "file not found: <path>", so those are rewritten too
+	var paths []string
+	r.Attrs(func(a slog.Attr) bool {
+		if redactedAttrKeys[a.Key] && a.Value.Kind() == slog.KindString && a.Value.String() != "" {
+			paths = append(paths, a.Value.String())
+		}
+		return true
+	})
+
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if redactedAttrKeys[a.Key] {
+			out.AddAttrs(h.redact(a))
+			return true
+		}
+		if len(paths) > 0 && (a.Value.Kind() == slog.KindString || a.Value.Kind() == slog.KindAny) {
+			text := a.Value.Resolve().String()
+			replaced := text
+			for _, p := range paths {
+				replaced = strings.ReplaceAll(replaced, p, RedactPath(h.mode, p))
+			}
+			if replaced != text {
+				a = slog.String(a.Key, replaced)
+			}
+		}
+		out.AddAttrs(a)
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted), mode: h.mode}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), mode: h.mode}
+}
+
+func (h *redactingHandler) redact(a slog.Attr) slog.Attr {
+	if redactedAttrKeys[a.Key] && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, RedactPath(h.mode, a.Value.String()))
+	}
+	return a
+}
@@ -0,0 +1,208 @@
+package vfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const maxSearchTokenLength = 64   // Longer tokens are not indexed
+const maxSearchMatchesPerFile = 5 // Snippets returned per matching file
+const maxSearchSnippetLength = 200
+
+// SearchMatch is a matching line within a file
+type SearchMatch struct {
+	Line int    `json:"line"` // 1-based line number
+	Text string `json:"text"` // The line, truncated to a snippet
+}
+
+// SearchResult lists the matching lines of one file
+type SearchResult struct {
+	Path    string        `json:"path"`
+	Matches []SearchMatch `json:"matches"`
+}
+
+// isSearchable reports whether files of this MIME type are searched
+func isSearchable(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || strings.HasPrefix(mimeType, "application/json")
+}
+
+// searchTokens splits text into lowercase words
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tokenKey returns the index key for a token. Keys are HMACs so the index
+// does not keep the plaintext vocabulary of the folder in memory.
+func (vfs *VirtualFileSystem) tokenKey(token string) string {
+	h := hmac.New(sha256.New, vfs.hmacKey)
+	h.Write([]byte(token))
+	return string(h.Sum(nil)[:16])
+}
+
+// indexFile adds the words of a file's plaintext to the search index
+func (vfs *VirtualFileSystem) indexFile(path string, data []byte) {
+	if vfs.searchIndex == nil {
+		vfs.searchIndex = make(map[string]map[string]struct{})
+	}
+	seen := make(map[string]bool)
+	for _, token := range searchTokens(string(data)) {
+		if len(token) > maxSearchTokenLength || seen[token] {
+			continue
+		}
+		seen[token] = true
+		key := vfs.tokenKey(token)
+		paths, ok := vfs.searchIndex[key]
+		if !ok {
+			paths = make(map[string]struct{})
+			vfs.searchIndex[key] = paths
+		}
+		paths[path] = struct{}{}
+	}
+}
+
+// Search is SearchWithIP for an anonymous, in-process caller
+func (vfs *VirtualFileSystem) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return vfs.SearchWithIP(ctx, query, "", limit)
+}
+
+// SearchWithIP returns the text files containing every word of query, with
+// the lines mentioning any of them. The client is checked once, as for
+// ReadFileWithIP: clients outside the IP rules, banned or locked are refused
+// with ErrAccessDenied. Files without read permission and files locked after
+// failed accesses are skipped. With Options.EnableSearchIndex only candidate
+// files from the index are decrypted; otherwise every text file is. A limit
+// <= 0 means no limit.
+func (vfs *VirtualFileSystem) SearchWithIP(ctx context.Context, query string, ipAddr string, limit int) ([]SearchResult, error) {
+	if err := vfs.checkIP("", ipAddr); err != nil {
+		return nil, err
+	}
+	ipAddr = vfs.AnonymizeIP(ipAddr)
+	if err := vfs.checkBan(ipAddr); err != nil {
+		return nil, err
+	}
+	if err := vfs.checkLockout("", ipAddr); err != nil {
+		return nil, err
+	}
+
+	var terms []string
+	for _, term := range searchTokens(query) {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+
+	var candidates []string
+	if vfs.options.EnableSearchIndex {
+		candidates = vfs.indexCandidates(terms)
+	} else {
+		for path, vfile := range vfs.files {
+			if isSearchable(vfile.MimeType) {
+				candidates = append(candidates, path)
+			}
+		}
+	}
+	sort.Strings(candidates)
+
+	var results []SearchResult
+	for _, path := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vfile := vfs.files[path]
+		if vfile == nil || (vfile.Permissions != nil && !vfile.Permissions.CanRead) {
+			continue
+		}
+		if vfs.checkLockout(path, ipAddr) != nil {
+			continue
+		}
+
+		data, err := vfs.openStored(vfile)
+		if err != nil {
+			vfs.logger().Warn("search: skipping unreadable file", "path", path, "error", err)
+			continue
+		}
+		matches := matchLines(data, terms)
+		zero(data)
+		if matches != nil {
+			results = append(results, SearchResult{Path: path, Matches: matches})
+			if limit > 0 && len(results) >= limit {
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// indexCandidates intersects the index posting lists of all terms
+func (vfs *VirtualFileSystem) indexCandidates(terms []string) []string {
+	var candidates map[string]struct{}
+	for _, term := range terms {
+		paths := vfs.searchIndex[vfs.tokenKey(term)]
+		if candidates == nil {
+			candidates = make(map[string]struct{}, len(paths))
+			for p := range paths {
+				candidates[p] = struct{}{}
+			}
+			continue
+		}
+		for p := range candidates {
+			if _, ok := paths[p]; !ok {
+				delete(candidates, p)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(candidates))
+	for p := range candidates {
+		result = append(result, p)
+	}
+	return result
+}
+
+// matchLines returns the lines of data that mention a term, or nil if the
+// file does not contain every term
+func matchLines(data []byte, terms []string) []SearchMatch {
+	found := make(map[string]bool, len(terms))
+	var matches []SearchMatch
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		hit := false
+		for _, token := range searchTokens(line) {
+			for _, term := range terms {
+				if token == term {
+					found[term] = true
+					hit = true
+				}
+			}
+		}
+		if hit && len(matches) < maxSearchMatchesPerFile {
+			if len(line) > maxSearchSnippetLength {
+				line = strings.ToValidUTF8(line[:maxSearchSnippetLength], "")
+			}
+			matches = append(matches, SearchMatch{Line: lineNo, Text: line})
+		}
+	}
+
+	if len(found) < len(terms) {
+		return nil
+	}
+	return matches
+}
@@ -0,0 +1,45 @@
+package vfs
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSearchChecksClient(t *testing.T) {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.LockAfterFailures = 2
+	fs, err := NewVirtualFileSystemFromMap(map[string][]byte{
+		"open.txt":   []byte("needle open"),
+		"locked.txt": []byte("needle locked"),
+	}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	// A file locked after failed accesses is skipped for everyone
+	fs.accessMu.Lock()
+	fs.accessLog["locked.txt"] = &FileAccessRecord{Path: "locked.txt", LockedUntil: time.Now().Add(time.Hour)}
+	fs.accessMu.Unlock()
+	results, err := fs.SearchWithIP(t.Context(), "needle", "192.0.2.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "open.txt" {
+		t.Fatalf("search found %+v, want only open.txt", results)
+	}
+
+	// A client locked for probing unknown paths is refused
+	for range 2 {
+		_, _ = fs.ReadFileWithIP("missing.txt", "192.0.2.2")
+	}
+	if _, err := fs.SearchWithIP(t.Context(), "needle", "192.0.2.2", 0); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("search by a locked client: error %v, want ErrAccessDenied", err)
+	}
+	if _, err := fs.SearchWithIP(t.Context(), "needle", "192.0.2.1", 0); err != nil {
+		t.Fatalf("search by another client: %v", err)
+	}
+}
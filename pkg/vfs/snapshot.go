@@ -0,0 +1,233 @@
+package vfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/previewer/pkg/acl"
+)
+
+// Snapshot file layout: snapshotMagic, one version byte, then a gob-encoded
+// snapshot. Bump snapshotVersion whenever the snapshot struct changes
+// incompatibly.
+const snapshotMagic = "PREVIEWER-VFS\x00"
+const snapshotVersion = 1
+
+// keyCheckLabel is MACed with the HMAC key so LoadSnapshot can tell a wrong
+// master key or passphrase apart from tampered files
+const keyCheckLabel = "previewer vfs snapshot key check"
+
+type snapshot struct {
+	RootPath  string
+	KeySalt   []byte
+	KeyCheck  []byte
+	CreatedAt time.Time
+	Files     []snapshotFile
+}
+
+type snapshotFile struct {
+	Path        string
+	Name        string
+	Data        []byte // Encrypted (possibly compressed) content, as stored
+	Size        int64
+	MimeType    string
+	Hash        string
+	HMAC        string
+	ModTime     time.Time
+	CreatedAt   time.Time
+	Compressed  bool
+	Permissions *acl.ItemPermissions
+}
+
+// keyCheck returns the MAC that identifies the current HMAC key
+func (vfs *VirtualFileSystem) keyCheck() []byte {
+	h := hmac.New(sha512.New, vfs.hmacKey)
+	h.Write([]byte(keyCheckLabel))
+	return h.Sum(nil)
+}
+
+// SaveSnapshot writes the encrypted file blobs and their metadata and HMACs
+// to w. Keys are never written, so only a VFS whose keys were derived from
+// Options.MasterKey or Options.Passphrase can be snapshotted; the key salt is
+// stored so LoadSnapshot can derive the same keys again.
+func (vfs *VirtualFileSystem) SaveSnapshot(w io.Writer) error {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+
+	if vfs.files == nil {
+		return fmt.Errorf("VFS has been cleaned up")
+	}
+	if vfs.keySalt == nil {
+		return fmt.Errorf("snapshot requires keys derived from MasterKey or Passphrase")
+	}
+
+	snap := snapshot{
+		RootPath:  vfs.rootPath,
+		KeySalt:   vfs.keySalt,
+		KeyCheck:  vfs.keyCheck(),
+		CreatedAt: vfs.createdAt,
+		Files:     make([]snapshotFile, 0, len(vfs.files)),
+	}
+	for _, vfile := range vfs.files {
+		snap.Files = append(snap.Files, snapshotFile{
+			Path:        vfile.Path,
+			Name:        vfile.Name,
+			Data:        vfile.Data,
+			Size:        vfile.Size,
+			MimeType:    vfile.MimeType,
+			Hash:        vfile.Hash,
+			HMAC:        vfile.HMAC,
+			ModTime:     vfile.ModTime,
+			CreatedAt:   vfile.CreatedAt,
+			Compressed:  vfile.isCompressed,
+			Permissions: vfile.Permissions,
+		})
+	}
+	sort.Slice(snap.Files, func(i, j int) bool { return snap.Files[i].Path < snap.Files[j].Path })
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(snapshotVersion); err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(bw).Encode(&snap); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	return bw.Flush()
+}
+
+// LoadSnapshot recreates a VFS from SaveSnapshot output without walking the
+// folder or decrypting anything. options must carry the same MasterKey or
+// Passphrase the snapshot was taken with; the salt comes from the snapshot.
+// Every entry must pass the checks a folder load applies under options
+// (path, MaxFileSize, MaxTotalSize, hidden files, Exclude, MIME types and
+// the manifest), or the whole snapshot is rejected: it is input, and may
+// have been written with laxer options or edited. .previewerignore is not
+// read. Files are verified as usual when read. The search index is not
+// persisted, so Search scans files instead of using the index.
+func LoadSnapshot(r io.Reader, options Options) (*VirtualFileSystem, error) {
+	if len(options.MasterKey) == 0 && options.Passphrase == "" {
+		return nil, fmt.Errorf("loading a snapshot requires MasterKey or Passphrase")
+	}
+
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("read snapshot header: %w", err)
+	}
+	if !bytes.Equal(header[:len(snapshotMagic)], []byte(snapshotMagic)) {
+		return nil, fmt.Errorf("not a VFS snapshot")
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (want %d)", version, snapshotVersion)
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(br).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	options.KeySalt = snap.KeySalt
+	options.EnableSearchIndex = false
+	return newVirtualFileSystem(snap.RootPath, options, func(vfs *VirtualFileSystem) error {
+		if !hmac.Equal(vfs.keyCheck(), snap.KeyCheck) {
+			return fmt.Errorf("snapshot key mismatch: wrong MasterKey or Passphrase")
+		}
+		filter, err := NewPathFilter("", vfs.options.Exclude)
+		if err != nil {
+			return err
+		}
+		vfs.filter = filter
+		for _, f := range snap.Files {
+			if err := vfs.checkSnapshotFile(&f); err != nil {
+				return fmt.Errorf("snapshot entry %q: %w", f.Path, err)
+			}
+			vfs.files[f.Path] = &VirtualFile{
+				Path:         f.Path,
+				Name:         f.Name,
+				Data:         f.Data,
+				Size:         f.Size,
+				MimeType:     f.MimeType,
+				Hash:         f.Hash,
+				HMAC:         f.HMAC,
+				ModTime:      f.ModTime,
+				CreatedAt:    f.CreatedAt,
+				Permissions:  f.Permissions,
+				isEncrypted:  true,
+				isCompressed: f.Compressed,
+			}
+			vfs.totalSize += f.Size
+		}
+		vfs.reportManifestMissing()
+		return nil
+	})
+}
+
+// checkSnapshotFile validates a snapshot entry against the options the VFS
+// is loaded with, filling in defaults for missing permissions. Callers hold
+// no locks; the VFS is not sealed yet.
+func (vfs *VirtualFileSystem) checkSnapshotFile(f *snapshotFile) error {
+	if err := vfs.ValidatePath(f.Path); err != nil {
+		return err
+	}
+	if f.Path == "" || normalizePath(f.Path) != f.Path {
+		return fmt.Errorf("path is not normalized")
+	}
+	if _, dup := vfs.files[f.Path]; dup {
+		return fmt.Errorf("duplicate entry")
+	}
+	if f.Name != filepath.Base(f.Path) {
+		return fmt.Errorf("name %q does not match the path", f.Name)
+	}
+	if f.Size < 0 || f.Size > vfs.options.MaxFileSize {
+		return fmt.Errorf("size %d exceeds the max file size", f.Size)
+	}
+	if vfs.totalSize+f.Size > vfs.options.MaxTotalSize {
+		return fmt.Errorf("total size limit reached")
+	}
+
+	// Hidden and excluded entries, the way the folder walk skips them
+	parts := strings.Split(filepath.ToSlash(f.Path), "/")
+	for i, part := range parts {
+		if IsHidden(part) && !vfs.options.IncludeHidden {
+			return fmt.Errorf("hidden entry")
+		}
+		if vfs.filter.Excluded(strings.Join(parts[:i+1], "/"), i < len(parts)-1) {
+			return fmt.Errorf("excluded entry")
+		}
+	}
+	if f.Path == PermissionsFileName {
+		return fmt.Errorf("permissions sidecar is not content")
+	}
+
+	if !vfs.mimeTypeAllowed(f.MimeType) {
+		return errMimeTypeBlocked
+	}
+	if hash, err := hex.DecodeString(f.Hash); err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("malformed hash")
+	}
+	if mac, err := hex.DecodeString(f.HMAC); err != nil || len(mac) != sha512.Size {
+		return fmt.Errorf("malformed HMAC")
+	}
+	if err := vfs.checkManifest(f.Path, f.Hash); err != nil {
+		return err
+	}
+	if f.Permissions == nil {
+		permissions := vfs.permissionsFor(f.Path)
+		f.Permissions = &permissions
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package vfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func snapshotOptions() Options {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.Passphrase = "correct horse battery staple"
+	options.IncludeHidden = true
+	return options
+}
+
+// takeSnapshot loads files with snapshotOptions and returns the decoded
+// snapshot, for tests to tamper with
+func takeSnapshot(t *testing.T, files map[string]string) snapshot {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs, err := NewVirtualFileSystemWithOptions(dir, snapshotOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+	var buf bytes.Buffer
+	if err := fs.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(buf.Bytes()[len(snapshotMagic)+1:])).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func encodeSnapshot(t *testing.T, snap snapshot) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	bw.WriteString(snapshotMagic)
+	bw.WriteByte(snapshotVersion)
+	if err := gob.NewEncoder(bw).Encode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	bw.Flush()
+	return &buf
+}
+
+func TestLoadSnapshotValidatesEntries(t *testing.T) {
+	snap := takeSnapshot(t, map[string]string{
+		"docs/readme.md": "# readme\n" + strings.Repeat("text ", 1000),
+		".env":           "SECRET=1",
+		"build/out.log":  "log",
+		"image.png":      "\x89PNG\r\n\x1a\n",
+	})
+
+	fs, err := LoadSnapshot(encodeSnapshot(t, snap), snapshotOptions())
+	if err != nil {
+		t.Fatalf("LoadSnapshot with the saving options: %v", err)
+	}
+	fs.SecureCleanup()
+
+	tamper := func(path string, change func(*snapshotFile)) snapshot {
+		out := snap
+		out.Files = append([]snapshotFile(nil), snap.Files...)
+		for i := range out.Files {
+			if out.Files[i].Path == path {
+				change(&out.Files[i])
+			}
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		snap    snapshot
+		options func(*Options)
+		want    string
+	}{
+		{"hidden", snap, func(o *Options) { o.IncludeHidden = false }, "hidden entry"},
+		{"excluded folder", snap, func(o *Options) { o.Exclude = []string{"build/"} }, "excluded entry"},
+		{"blocked type", snap, func(o *Options) { o.BlockedMimeTypes = []string{"image/*"} }, "MIME type not allowed"},
+		{"file too large", snap, func(o *Options) { o.MaxFileSize = 2048 }, "exceeds the max file size"},
+		{"total too large", snap, func(o *Options) { o.MaxTotalSize = 4096 }, "total size limit"},
+		{"traversal", tamper("docs/readme.md", func(f *snapshotFile) { f.Path = "../etc/passwd" }), nil, "snapshot entry"},
+		{"unnormalized", tamper("docs/readme.md", func(f *snapshotFile) { f.Path = "docs//readme.md" }), nil, "not normalized"},
+		{"duplicate", tamper("image.png", func(f *snapshotFile) { f.Path, f.Name = "docs/readme.md", "readme.md" }), nil, "duplicate entry"},
+		{"name", tamper("docs/readme.md", func(f *snapshotFile) { f.Name = "other.md" }), nil, "does not match the path"},
+		{"negative size", tamper("docs/readme.md", func(f *snapshotFile) { f.Size = -1 }), nil, "max file size"},
+		{"hash", tamper("docs/readme.md", func(f *snapshotFile) { f.Hash = "zz" }), nil, "malformed hash"},
+		{"hmac", tamper("docs/readme.md", func(f *snapshotFile) { f.HMAC = f.HMAC[:10] }), nil, "malformed HMAC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := snapshotOptions()
+			if tt.options != nil {
+				tt.options(&options)
+			}
+			fs, err := LoadSnapshot(encodeSnapshot(t, tt.snap), options)
+			if err == nil {
+				fs.SecureCleanup()
+				t.Fatal("LoadSnapshot accepted the snapshot")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("LoadSnapshot error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package vfs
+
+import "time"
+
+// DefaultAccessRecordTTL is how long an access record is kept after the
+// file was last accessed when Options.AccessRecordTTL is zero
+const DefaultAccessRecordTTL = 1 * time.Hour
+
+const defaultAccessSweepInterval = 5 * time.Minute
+const flaggedRecordTTLFactor = 4 // Records with suspicious flags are kept this many TTLs
+
+// accessRecordTTL applies the default to Options.AccessRecordTTL. Records are
+// never dropped before the rate limit window has passed.
+func (vfs *VirtualFileSystem) accessRecordTTL() time.Duration {
+	ttl := vfs.options.AccessRecordTTL
+	if ttl == 0 {
+		ttl = DefaultAccessRecordTTL
+	}
+	if ttl < rateLimitWindow {
+		ttl = rateLimitWindow
+	}
+	return ttl
+}
+
+// startSweeper prunes stale access records in the background until
+// SecureCleanup, which is what lets the VFS be freed: the goroutine holds it.
+// A negative Options.AccessRecordTTL keeps records forever (and starts no
+// goroutine).
+func (vfs *VirtualFileSystem) startSweeper() {
+	if vfs.options.AccessRecordTTL < 0 {
+		return
+	}
+	interval := vfs.options.AccessSweepInterval
+	if interval <= 0 {
+		interval = defaultAccessSweepInterval
+	}
+	vfs.stopSweep = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-vfs.stopSweep:
+				return
+			case now := <-ticker.C:
+				if pruned := vfs.pruneAccessLog(now); pruned > 0 {
+					vfs.logger().Debug("pruned stale access records", "records", pruned)
+				}
+			}
+		}
+	}()
+}
+
+// stopSweeper ends the background sweeper, if one is running
+func (vfs *VirtualFileSystem) stopSweeper() {
+	vfs.sweepOnce.Do(func() {
+		if vfs.stopSweep != nil {
+			close(vfs.stopSweep)
+		}
+	})
+}
+
+// pruneAccessLog drops the access records not used since the TTL, keeping
+// records with suspicious flags for longer and locked ones until the lock
+// ends, and returns how many it dropped. Expired client locks and bans are
+// cleared.
+func (vfs *VirtualFileSystem) pruneAccessLog(now time.Time) int {
+	ttl := vfs.accessRecordTTL()
+
+	vfs.accessMu.Lock()
+	defer vfs.accessMu.Unlock()
+
+	pruned := 0
+	for path, record := range vfs.accessLog {
+		keep := ttl
+		if len(record.SuspiciousFlags) > 0 {
+			keep = ttl * flaggedRecordTTLFactor
+		}
+		if now.Sub(record.LastAccess) > keep && !now.Before(record.LockedUntil) {
+			delete(vfs.accessLog, path)
+			pruned++
+		}
+	}
+	for ip, until := range vfs.lockedIPs {
+		if !now.Before(until) {
+			delete(vfs.lockedIPs, ip)
+		}
+	}
+	vfs.pruneBans(now)
+	return pruned
+}
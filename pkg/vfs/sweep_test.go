@@ -0,0 +1,69 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseStopsSweeper(t *testing.T) {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.AccessSweepInterval = time.Millisecond
+
+	before := runtime.NumGoroutine()
+	fs, err := NewVirtualFileSystemWithOptions(t.TempDir(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.NumGoroutine() <= before {
+		t.Fatal("no sweeper goroutine started")
+	}
+
+	var closer io.Closer = fs
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	fs.SecureCleanup() // A second cleanup is harmless
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Close, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Reads still in flight when SecureCleanup runs must neither race with it
+// nor write to the access maps it has dropped (run with -race)
+func TestSecureCleanupDuringReads(t *testing.T) {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.LockAfterFailures = 2
+	options.AutoBanAfter = 1
+	fs, err := NewVirtualFileSystemFromMap(map[string][]byte{"a.txt": []byte("a")}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			ip := fmt.Sprintf("192.0.2.%d", i+1)
+			for range 200 {
+				if file, err := fs.ReadFileWithIP("a.txt", ip); err == nil {
+					clear(file.Data)
+				}
+				fs.ReadFileWithIP("missing.txt", ip)
+			}
+		})
+	}
+	time.Sleep(time.Millisecond)
+	fs.SecureCleanup()
+	wg.Wait()
+}
@@ -3,6 +3,7 @@ package vfs
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
@@ -10,81 +11,229 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"math"
-	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/oarkflow/previewer/pkg/acl"
+	"github.com/oarkflow/previewer/pkg/render"
 )
 
 // LogCallback is a function type for security incident logging
-// Users can set a custom callback to send security breach logs to backend
+// Users can set a custom callback to send security breach logs to backend.
+// The data map is built by NewIncident; file.LogCallback is the same type.
 type LogCallback func(data map[string]any)
 
-// Global callback for security incidents (default is no-op)
-var securityLogCallback LogCallback = func(data map[string]any) {
-
-}
+// Default callback of VFSs created without Options.LogCallback (see
+// SetLogCallback)
+var (
+	defaultCallbackMu   sync.RWMutex
+	securityLogCallback LogCallback
+)
 
-// SetLogCallback allows users to set a custom callback for security incidents
-// This enables sending security breach logs to external systems (e.g., backend API, SIEM)
+// SetLogCallback sets the callback that VFSs created afterwards use when
+// their Options.LogCallback is nil. It is read once, at creation: every VFS
+// has a single callback of its own, which (*VirtualFileSystem).SetLogCallback
+// replaces. A nil callback is ignored.
 //
-// Example usage:
-//
-//	vfs.SetLogCallback(func(data map[string]any) {
-//	    // Send to backend
-//	    sendToBackend("/api/security-log", data)
-//	})
+// Deprecated: set Options.LogCallback, or call SetLogCallback on the
+// instance. A process-wide default leaks incidents between unrelated VFSs.
 func SetLogCallback(callback LogCallback) {
 	if callback != nil {
+		defaultCallbackMu.Lock()
 		securityLogCallback = callback
+		defaultCallbackMu.Unlock()
 	}
 }
 
-// logSecurityIncident logs a security incident and invokes the callback
-func logSecurityIncident(incidentType, severity, message string, details map[string]any) {
-	data := map[string]any{
+// instanceCallback returns the callback a new VFS starts with: cb, or the
+// SetLogCallback default when cb is nil
+func instanceCallback(cb LogCallback) LogCallback {
+	if cb != nil {
+		return cb
+	}
+	defaultCallbackMu.RLock()
+	defer defaultCallbackMu.RUnlock()
+	return securityLogCallback
+}
+
+// Incident sources. Server incidents are detected by this process; frontend
+// incidents are reported by the viewer and cannot be trusted as much.
+const (
+	IncidentSourceServer   = "server"
+	IncidentSourceFrontend = "frontend"
+)
+
+// NewIncident builds the incident payload handed to a LogCallback, with
+// source IncidentSourceServer
+func NewIncident(incidentType, severity, message string, details map[string]any) map[string]any {
+	return map[string]any{
 		"timestamp":     time.Now().Unix(),
 		"incident_type": incidentType,
-		"severity":      severity,
+		"severity":      severity, // "low", "medium", "high", "critical"
 		"message":       message,
 		"details":       details,
+		"source":        IncidentSourceServer,
+	}
+}
+
+// defaultLogger returns the logger used when Options.Logger is nil.
+// It writes to stderr, matching the behaviour of the standard log package.
+// Routine per-file and per-access messages are logged at debug level and
+// only show up when verbose is set.
+func defaultLogger(verbose bool) *slog.Logger {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// severityLevel maps an incident severity to the slog level it is logged at
+func severityLevel(severity string) slog.Level {
+	switch severity {
+	case "high", "critical":
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// logSecurityIncident logs a security incident and invokes the callback
+func (vfs *VirtualFileSystem) logSecurityIncident(incidentType, severity, message string, details map[string]any) {
+	data := NewIncident(incidentType, severity, message, details)
+	if !vfs.options.IncidentFullPaths {
+		data = RedactIncident(vfs.options.RedactPaths, data)
 	}
 
 	// Always log to console
-	log.Printf("[SECURITY %s] %s: %s", strings.ToUpper(severity), incidentType, message)
+	vfs.logger().Log(context.Background(), severityLevel(severity), "security incident",
+		"severity", strings.ToUpper(severity), "type", incidentType, "message", message)
+	vfs.writeAudit(AuditEventIncident, data)
+
+	vfs.logMu.RLock()
+	cb := vfs.logCallback
+	vfs.logMu.RUnlock()
+	if cb != nil {
+		cb(data)
+	}
+}
 
-	// Invoke user callback
-	securityLogCallback(data)
+// SetLogCallback replaces the security incident callback of this VFS, so
+// that several instances in one process each route to their own sink.
+// Passing nil turns the callback off.
+func (vfs *VirtualFileSystem) SetLogCallback(callback LogCallback) {
+	vfs.logMu.Lock()
+	defer vfs.logMu.Unlock()
+	vfs.logCallback = callback
 }
 
+// ShutdownTimeout is the default of Options.ShutdownTimeout
 const ShutdownTimeout = 5 * time.Second
+
+// DefaultSessionTimeout is how long a preview may sit idle before the server shuts down
+const DefaultSessionTimeout = 30 * time.Minute
+
+// DefaultStartupConnectTimeout is how long the server waits for the first browser connection
+const DefaultStartupConnectTimeout = 60 * time.Second
+
+// Security presets for Options.SecurityPreset
+const (
+	SecurityPresetStandard = "standard" // Copy and download allowed, no watermark (folder preview default)
+	SecurityPresetStrict   = "strict"   // No copy, no download, screenshot resistance and watermark (single-file default)
+)
 const defaultMaxFileSize = 100 * 1024 * 1024 // 100MB max per file
 const defaultMaxTotalSize = 500 * 1024 * 1024 // 500MB max total
 const defaultMaxAccessPerFile = 1000 // Max access attempts per file
 const rateLimitWindow = 1 * time.Minute // Rate limit time window
 const maxPathLength = 4096 // Maximum path length
 const encryptionKeySize = 32 // AES-256
-const compressionThreshold = 1024 // Compress files > 1KB
+const defaultCompressionThreshold = 1024 // Compress files of 1KB and more
 
 // Options configures VFS behavior
 type Options struct {
 	MaxFileSize       int64 // Maximum size per file
 	MaxTotalSize      int64 // Maximum total folder size
 	EnableCompression bool  // Enable gzip compression for text files
-	LogCallback	  LogCallback // Custom log callback for security incidents
+	CompressionThreshold int64 // Smallest file EnableCompression compresses, in bytes (default 1 KB)
+	CompressibleMimeTypes []string // MIME types (or prefixes ending in "/") EnableCompression applies to (default text, JSON, XML and JavaScript types)
+	CompressionLevel  int   // gzip level of EnableCompression, gzip.BestSpeed (1) to gzip.BestCompression (9); higher levels keep text folders smaller in memory but take longer to load (0 means gzip.DefaultCompression)
+	LogCallback	  LogCallback // Receives every security incident; the preview server adds its own incidents and the viewer's
 	MaxAccessPerFile  int   // Rate limit per file
 	AnomalyThreshold  int   // Anomaly detection threshold (0-100)
+	LockAfterFailures int   // Refuse a file, or a client probing invalid paths, for LockDuration after this many failures (0 disables)
+	LockDuration      time.Duration // Cooldown of a LockAfterFailures lock (default 15m)
+	AutoBanAfter      int   // Ban a client for BanDuration after this many of its reads push a file over AnomalyThreshold (0 disables)
+	BanDuration       time.Duration // Length of an AutoBanAfter ban, and how long strikes take to decay (default 10m)
+	AllowedIPs        []string // If non-empty, only these client IPs or CIDR ranges may read files
+	BlockedIPs        []string // Client IPs or CIDR ranges that may never read files; takes precedence over AllowedIPs
 	MLockMemory       bool  // Lock memory to prevent swapping
+	Logger            *slog.Logger // Destination for VFS logs (defaults to stderr)
+	Verbose           bool  // Log routine per-file and per-access messages on the default logger
+	FullHMACHeader    bool  // Send the full HMAC in X-File-HMAC instead of a 16-char identifier
+	AllowCaching      bool  // Let browsers cache /api/file responses (ETag + If-None-Match); default is no-store
+	ThumbnailMaxDimension int // Longest side of /api/thumbnail images in pixels (default 256)
+	EnableSearchIndex bool  // Build an inverted index of text files at load time for Search
+	SniffMimeType     bool  // Detect MIME type from content when the extension is unknown
+	MimeTypes         map[string]string // Extra extension -> MIME type mappings (e.g. ".proto": "text/plain")
+	MimeTypeOverrides map[string]string // Per-file MIME types by slash-separated path in the folder (e.g. "data/export": "text/csv"); win over MimeTypes and sniffing
+	ContentSecurityPolicy string // CSP for preview pages; "{nonce}" is replaced with the script nonce (default is strict)
+	AllowAnyWSOrigin  bool  // Development only: accept WebSocket connections from any Origin
+	SessionTimeout    time.Duration // Shut down after this long without file reads or WebSocket messages (default 30m)
+	StartupConnectTimeout time.Duration // Shut down if no browser connects over WebSocket within this long (default 60s)
+	ShutdownTimeout   time.Duration // How long in-flight requests get to finish when the server stops (default 5s)
+	ListenHost        string // Interface the preview server binds to (default 127.0.0.1); a non-loopback host exposes the decrypted files to the network
+	BasicAuthUser     string // HTTP basic auth user name; required (with the password) when ListenHost is not loopback
+	BasicAuthPassword string // HTTP basic auth password
+	RequireAuth       bool   // Enforce basic auth on loopback too
+	ShareSecret       []byte // Key that signs share links (file.NewShareToken); unset disables ?token= access
+	ShareTTL          time.Duration // Log a share link for SharePath valid this long at startup, with a random ShareSecret if unset
+	SharePath         string        // File the startup share link is limited to (default the whole folder)
+	WSPingInterval    time.Duration // Ping browsers this often; a connection silent for 3 intervals is dropped (default 20s)
+	SecurityPreset    string // Security preset for the preview UI: SecurityPresetStandard (default) or SecurityPresetStrict
+	Watch             bool   // Reload the folder and refresh connected browsers when files change on disk
+	IncludeHidden     bool   // Load and list dotfiles and dotfolders (default false)
+	FolderSummarySize int    // Number of files in FolderMeta's RecentFiles and LargestFiles (default 10, negative disables)
+	LazyTree          bool   // Embed only top-level items in the page; folder children load from /api/tree?path=
+	AllowedMimeTypes  []string // If non-empty, only these types are loaded and served ("application/pdf", "image/*")
+	BlockedMimeTypes  []string // Types never loaded or served; takes precedence over AllowedMimeTypes
+	Exclude           []string // gitignore-style patterns to leave out, in addition to the folder's .previewerignore
+	MaxInlinePreviewBytes int64 // Largest file opened in the viewer (default 20 MB); larger ones get a page linking to /api/file
+	MaxInlineBytes    int64  // Largest file whose bytes are embedded into its preview page (default 2 MB); the viewer fetches larger ones from /api/file
+	AssetsFS          fs.FS  // Viewer assets (index.html and static files) used instead of the bundled dist
+	IndexHTML         []byte // Custom viewer page used instead of the bundled index.html; must contain </head>
+	MasterKey         []byte // Derive the encryption and HMAC keys from this secret (at least 32 bytes) instead of random keys
+	Passphrase        string // Derive the keys from a passphrase via PBKDF2; mutually exclusive with MasterKey
+	KeySalt           []byte // Salt for MasterKey/Passphrase derivation; generated when empty (see KeySalt())
+	RedactPaths       string // Log file paths as RedactPathsBasename or RedactPathsHash above debug level (default: full paths)
+	IncidentFullPaths bool   // With RedactPaths, still give incident callbacks the full paths
+	DisableRequestLogging bool // Don't log one line per HTTP request (they include the requested paths)
+	Silent            bool   // Discard all log output, Logger included; incident callbacks and AuditLogWriter still get everything
+	MaxIncidentBodyBytes int64 // Largest incident report accepted from the viewer (default 64 KB)
+	AdminToken        string // Bearer token for admin endpoints such as /api/file-stats; empty disables them
+	StatsIncludeIPs   bool   // Include client IPs in /api/file-stats responses (default: counts only)
+	AccessRecordTTL   time.Duration // Drop access records unused for this long (default 1h; flagged records 4x longer; negative keeps them)
+	AccessSweepInterval time.Duration // How often stale access records are pruned (default 5m)
+	AnonymizeIPs      bool   // Store and log client IPs as keyed hashes instead of raw addresses (see AnonymizeIP)
+	TrustProxyHeaders bool   // Take the client IP from the right-most X-Forwarded-For entry; only behind a reverse proxy that sets it, since clients can send the header
+	PDFRasterizer     render.PDFRasterizer // Serve PDFs as page images via /api/pdf-page; with NoDownload the raw PDF is never sent
+	Converter         render.DocumentConverter // Preview office documents (render.IsOfficeType) as PDF converted on the fly; the VFS keeps the original
+	ArchivePassword   string // Password for encrypted entries (ZipCrypto or WinZip AES) of NewVirtualFileSystemFromArchive
+	Manifest          map[string]string // Trusted SHA-256 per slash-separated path (see ReadManifest); mismatching files are refused, unlisted ones reported
+	AuditLogWriter    io.Writer // Receives every incident and file access as a line of JSON (see WriteAudit); nil disables
 }
 
 // DefaultOptions returns default configuration
@@ -96,6 +245,7 @@ func DefaultOptions() Options {
 		MaxAccessPerFile:  defaultMaxAccessPerFile,
 		AnomalyThreshold:  75,
 		MLockMemory:       false,
+		SniffMimeType:     true,
 	}
 }
 
@@ -106,9 +256,12 @@ type FileAccessRecord struct {
 	LastAccess      time.Time
 	FirstAccess     time.Time
 	FailedAttempts  int
-	IPAddresses     map[string]int // Track which IPs accessed
+	IPAddresses     map[string]int // Track which IPs accessed (at most maxIPsPerRecord)
+	UniqueIPs       int            // Distinct IPs seen; an upper bound once IPAddresses is full
+	OtherIPAccesses int            // Accesses from IPs not kept in IPAddresses
 	AnomalyScore    float64        // ML anomaly score
 	SuspiciousFlags []string       // List of suspicious behaviors
+	LockedUntil     time.Time      // Reads are refused until then (Options.LockAfterFailures)
 }
 
 // VirtualFile represents a file stored in memory with tamper protection
@@ -128,20 +281,44 @@ type VirtualFile struct {
 	isCompressed bool      // Flag indicating compression status
 }
 
-// VirtualFileSystem represents a secure tamper-proof in-memory filesystem sandbox
+// VirtualFileSystem represents a secure tamper-proof in-memory filesystem sandbox.
+// Every VFS returned by a constructor runs a background sweeper that keeps it
+// reachable: call SecureCleanup (or Close) when done with it, or the VFS,
+// its keys and its encrypted files are never freed.
 type VirtualFileSystem struct {
 	rootPath      string // Original folder path (for reference only)
 	files         map[string]*VirtualFile // Path -> VirtualFile
 	totalSize     int64
 	mu            sync.RWMutex
+	rekeyMu       sync.Mutex // Serializes Reload and RotateKeys, so a reload never encrypts with keys being rotated
 	readOnly      bool
 	encryptionKey []byte // AES-256 key for data encryption
 	hmacKey       []byte // Separate key for HMAC
-	accessLog     map[string]*FileAccessRecord // Path -> Access tracking
+	keySalt       []byte // Salt the keys were derived with (nil for random keys)
+	filter        *PathFilter // Exclude and .previewerignore rules (nil = none)
+	excluded      int         // Entries skipped by filter during the last load
+	accessLog     map[string]*FileAccessRecord // Path -> Access tracking, for files in the VFS only
+	invalidAccess map[string]int // Client IP -> rejected or unknown paths requested (see trackInvalidAccess)
+	invalidOverflow int // Invalid attempts from clients beyond maxTrackedClients
+	lockedIPs     map[string]time.Time // Client IP -> end of its lockout (see lockClientOnFailure)
+	ipBans        map[string]time.Time // Client IP -> end of its ban (see strikeIP)
+	ipStrikes     map[string]ipStrikes // Client IP -> anomalous reads counting towards a ban
 	accessMu      sync.RWMutex
 	createdAt     time.Time
 	sealed        bool       // Once sealed, no modifications allowed
 	options       Options // Configuration options
+	logCallback   LogCallback // Incident callback (nil = none), see instanceCallback
+	logMu         sync.RWMutex
+	searchIndex   map[string]map[string]struct{} // Keyed token digest -> paths (see search.go)
+	ipSalt        []byte // Per-process key for AnonymizeIP
+	ipRules       ipRules // Parsed Options.AllowedIPs and Options.BlockedIPs
+	manifest      map[string]string // Normalized Options.Manifest (nil = no verification)
+	skipped       []SkipInfo // Entries left out by the last load (see skipFile)
+	permissions   map[string]acl.ItemPermissions // From the folder's .permissions.json (nil = defaults)
+	dryRun        bool       // Record what would be stored without encrypting it (see DryRun)
+	integrity     integrityCounters // Verification failures by mode
+	stopSweep     chan struct{} // Closed to stop the access record sweeper
+	sweepOnce     sync.Once
 }
 
 // NewVirtualFileSystem creates a new in-memory filesystem from a folder with encryption
@@ -151,23 +328,103 @@ func NewVirtualFileSystem(folderPath string) (*VirtualFileSystem, error) {
 
 // NewVirtualFileSystemWithOptions creates a VFS with custom options
 func NewVirtualFileSystemWithOptions(folderPath string, options Options) (*VirtualFileSystem, error) {
-	// Generate cryptographic keys for encryption and HMAC
-	encryptionKey := make([]byte, encryptionKeySize)
-	hmacKey := make([]byte, encryptionKeySize)
+	return newVirtualFileSystem(folderPath, options, func(vfs *VirtualFileSystem) error {
+		return vfs.loadRoot()
+	})
+}
+
+// NewVirtualFileSystemFromMap creates a VFS from in-memory content, keyed by
+// relative path (e.g. "docs/readme.md"). Each entry goes through the same
+// encryption, compression and HMAC pipeline and size limits as files loaded
+// from disk. The resulting VFS has no source folder, so Reload and Watch are
+// not available.
+func NewVirtualFileSystemFromMap(files map[string][]byte, options Options) (*VirtualFileSystem, error) {
+	return newVirtualFileSystem("", options, func(vfs *VirtualFileSystem) error {
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			if err := vfs.ValidatePath(path); err != nil {
+				return fmt.Errorf("%q: %w", path, err)
+			}
+			relPath := normalizePath(path)
+			if relPath == "." || relPath == "" {
+				return fmt.Errorf("%q: invalid path: empty", path)
+			}
+			data := files[path]
+
+			if int64(len(data)) > vfs.options.MaxFileSize {
+				vfs.logger().Warn("skipping file: exceeds max size",
+					"path", relPath, "max_mb", vfs.options.MaxFileSize/(1024*1024))
+				vfs.skipFile(relPath, SkipTooLarge)
+				continue
+			}
+			if vfs.totalSize+int64(len(data)) > vfs.options.MaxTotalSize {
+				vfs.logger().Warn("stopping file loading: total size limit reached",
+					"max_mb", vfs.options.MaxTotalSize/(1024*1024))
+				vfs.skipFile(relPath, SkipTotalLimit)
+				return nil
+			}
+
+			if err := vfs.storeFile(relPath, data, vfs.createdAt); errors.Is(err, errMimeTypeBlocked) {
+				vfs.logger().Warn("skipping file: MIME type not allowed", "path", relPath)
+				vfs.skipFile(relPath, SkipMimeType)
+			} else if errors.Is(err, errManifestMismatch) {
+				vfs.logger().Warn("skipping file: does not match the manifest", "path", relPath)
+				vfs.skipFile(relPath, SkipManifest)
+			} else if err != nil {
+				return fmt.Errorf("%q: encryption failed: %w", path, err)
+			}
+		}
+		vfs.reportManifestMissing()
+		return nil
+	})
+}
 
-	if _, err := io.ReadFull(rand.Reader, encryptionKey); err != nil {
-		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+// newVirtualFileSystem generates keys, runs load to populate the files and
+// seals the VFS
+func newVirtualFileSystem(folderPath string, options Options, load func(vfs *VirtualFileSystem) error) (*VirtualFileSystem, error) {
+	if options.Silent {
+		options.Logger = slog.New(slog.DiscardHandler)
 	}
-	if _, err := io.ReadFull(rand.Reader, hmacKey); err != nil {
-		return nil, fmt.Errorf("failed to generate HMAC key: %w", err)
+	if options.Logger == nil {
+		options.Logger = defaultLogger(options.Verbose)
+	}
+	if err := validRedactMode(options.RedactPaths); err != nil {
+		return nil, err
+	}
+	options.Logger = RedactingLogger(options.Logger, options.RedactPaths)
+	rules, err := newIPRules(options)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := newManifest(options.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := validCompressionLevel(options.CompressionLevel); err != nil {
+		return nil, err
 	}
 
+	// Generate (or derive from the master key) keys for encryption and HMAC
+	encryptionKey, hmacKey, keySalt, err := initialKeys(options)
+	if err != nil {
+		return nil, err
+	}
+	// The secret is not needed once the keys exist; don't keep it around
+	options.MasterKey = nil
+	options.Passphrase = ""
+	options.ArchivePassword = ""
+
 	// Lock memory to prevent swapping if requested (requires privileges)
 	if options.MLockMemory {
 		if err := syscall.Mlockall(syscall.MCL_CURRENT | syscall.MCL_FUTURE); err != nil {
-			log.Printf("Warning: Failed to lock memory (requires root): %v", err)
+			options.Logger.Warn("failed to lock memory (requires root)", "error", err)
 		} else {
-			log.Println("Memory locked: swap protection enabled")
+			options.Logger.Info("memory locked: swap protection enabled")
 		}
 	}
 
@@ -175,28 +432,53 @@ func NewVirtualFileSystemWithOptions(folderPath string, options Options) (*Virtu
 		rootPath:      folderPath,
 		files:         make(map[string]*VirtualFile),
 		accessLog:     make(map[string]*FileAccessRecord),
+		invalidAccess: make(map[string]int),
+		lockedIPs:     make(map[string]time.Time),
+		ipBans:        make(map[string]time.Time),
+		ipStrikes:     make(map[string]ipStrikes),
 		readOnly:      true,
 		encryptionKey: encryptionKey,
 		hmacKey:       hmacKey,
+		keySalt:       keySalt,
+		ipSalt:        newIPSalt(),
+		ipRules:       rules,
+		manifest:      manifest,
 		createdAt:     time.Now(),
 		sealed:        false,
 		options:       options,
+		logCallback:   instanceCallback(options.LogCallback),
 	}
 
-	err := vfs.loadFolder(folderPath, "")
-	if err != nil {
-		return nil, fmt.Errorf("failed to load folder into VFS: %w", err)
+	if err := vfs.selfTest(); err != nil {
+		return nil, err
+	}
+
+	if err := load(vfs); err != nil {
+		return nil, err
 	}
 
 	// Seal the VFS - no more modifications allowed
 	vfs.sealed = true
+	vfs.startSweeper()
 
-	log.Printf("VFS initialized: %d files, total size: %.2f MB, encrypted: YES, compressed: %v, sealed: YES",
-		len(vfs.files), float64(vfs.totalSize)/(1024*1024), options.EnableCompression)
+	options.Logger.Info("VFS initialized",
+		"files", len(vfs.files),
+		"total_size_mb", float64(vfs.totalSize)/(1024*1024),
+		"encrypted", true,
+		"compressed", options.EnableCompression,
+		"sealed", true)
 
 	return vfs, nil
 }
 
+// logger returns the configured logger, falling back to stderr
+func (vfs *VirtualFileSystem) logger() *slog.Logger {
+	if vfs.options.Logger != nil {
+		return vfs.options.Logger
+	}
+	return defaultLogger(vfs.options.Verbose)
+}
+
 // encryptData encrypts data using AES-256-GCM
 func (vfs *VirtualFileSystem) encryptData(plaintext []byte) ([]byte, error) {
 	block, err := aes.NewCipher(vfs.encryptionKey)
@@ -256,10 +538,57 @@ func (vfs *VirtualFileSystem) verifyHMAC(data []byte, expectedHMAC string) bool
 	return hmac.Equal([]byte(actualHMAC), []byte(expectedHMAC))
 }
 
-// compressData compresses data using gzip
+// openStored decrypts, decompresses and verifies a stored file without any
+// access tracking or incident logging. Callers must hold vfs.mu.
+func (vfs *VirtualFileSystem) openStored(vfile *VirtualFile) ([]byte, error) {
+	data, err := vfs.decryptData(vfile.Data)
+	if err != nil {
+		vfs.countIntegrityFailure(failDecrypt)
+		return nil, err
+	}
+	if vfile.isCompressed {
+		decrypted := data
+		data, err = vfs.decompressData(decrypted, vfile.Size)
+		zero(decrypted)
+		if err != nil {
+			vfs.countIntegrityFailure(failDecompress)
+			return nil, err
+		}
+	}
+	if !vfs.verifyHMAC(data, vfile.HMAC) {
+		zero(data)
+		vfs.countIntegrityFailure(failHMAC)
+		return nil, fmt.Errorf("HMAC verification failed")
+	}
+	hash := sha256.Sum256(data)
+	if hex.EncodeToString(hash[:]) != vfile.Hash {
+		zero(data)
+		vfs.countIntegrityFailure(failHash)
+		return nil, fmt.Errorf("hash mismatch")
+	}
+	return data, nil
+}
+
+// validCompressionLevel reports whether level is a usable
+// Options.CompressionLevel
+func validCompressionLevel(level int) error {
+	if level < 0 || level > gzip.BestCompression {
+		return fmt.Errorf("compression level %d out of range (want %d to %d, or 0 for the default)", level, gzip.BestSpeed, gzip.BestCompression)
+	}
+	return nil
+}
+
+// compressData compresses data using gzip at Options.CompressionLevel
 func (vfs *VirtualFileSystem) compressData(data []byte) ([]byte, error) {
+	level := vfs.options.CompressionLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
 	var buf bytes.Buffer
-	writer := gzip.NewWriter(&buf)
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
 
 	if _, err := writer.Write(data); err != nil {
 		writer.Close()
@@ -273,15 +602,94 @@ func (vfs *VirtualFileSystem) compressData(data []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// decompressData decompresses gzip data
-func (vfs *VirtualFileSystem) decompressData(data []byte) ([]byte, error) {
+// decompressMargin is how far past its recorded size a stream may expand
+// before decompression gives up
+const decompressMargin = 4 * 1024
+
+// ErrDecompressionLimit is returned when a gzip stream expands past the size
+// recorded for its file, as a corrupted or hostile blob would
+var ErrDecompressionLimit = errors.New("decompressed data exceeds the recorded file size")
+
+// decompressLimit is how far a stream recorded as size bytes may expand:
+// size plus decompressMargin, and never more than MaxFileSize, which no
+// stored file can legitimately exceed, unless size itself is larger (the
+// self-test vector is not a stored file)
+func (vfs *VirtualFileSystem) decompressLimit(size int64) int64 {
+	maxSize := vfs.options.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxFileSize
+	}
+	return min(max(size, 0)+decompressMargin, max(maxSize, size))
+}
+
+// decompressData decompresses gzip data that should expand to size bytes,
+// capped at decompressLimit. The output buffer is allocated for size up front; a stream that runs longer grows it
+// with the outgrown buffers cleared, so no plaintext copies are left behind.
+func (vfs *VirtualFileSystem) decompressData(data []byte, size int64) ([]byte, error) {
 	reader, err := gzip.NewReader(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
 
-	return io.ReadAll(reader)
+	limit := vfs.decompressLimit(size)
+	limited := io.LimitReader(reader, limit+1)
+	out := make([]byte, 0, max(size, 0)+1) // The extra byte sees EOF without growing
+	for {
+		if len(out) == cap(out) {
+			grown := make([]byte, len(out), min(2*int64(cap(out)), limit+1))
+			copy(grown, out)
+			clear(out)
+			out = grown
+		}
+		n, err := limited.Read(out[len(out):cap(out)])
+		out = out[:len(out)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			clear(out)
+			return nil, err
+		}
+	}
+	if int64(len(out)) > limit {
+		clear(out)
+		vfs.options.Logger.Warn("decompression stopped at the size limit",
+			"compressed_bytes", len(data), "expected_bytes", size, "limit_bytes", limit)
+		return nil, ErrDecompressionLimit
+	}
+	return out, nil
+}
+
+// detectMimeType picks a MIME type from Options.MimeTypeOverrides, then the
+// file extension, falling back to sniffing the first 512 bytes when the
+// extension is unknown (if enabled). Empty files are not sniffed:
+// http.DetectContentType calls them text/plain.
+func (vfs *VirtualFileSystem) detectMimeType(relPath string, data []byte) string {
+	if mimeType, ok := vfs.options.MimeTypeOverrides[filepath.ToSlash(relPath)]; ok {
+		return mimeType
+	}
+	if mimeType := MimeTypeByExtension(filepath.Base(relPath), vfs.options.MimeTypes); mimeType != "" {
+		return mimeType
+	}
+	if vfs.options.SniffMimeType && len(data) > 0 {
+		return http.DetectContentType(data[:min(len(data), 512)])
+	}
+	return "application/octet-stream"
+}
+
+// defaultCompressibleTypes are the text-based types compressed unless
+// Options.CompressibleMimeTypes says otherwise
+var defaultCompressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"application/x-javascript",
+	"application/ecmascript",
+	"application/rss+xml",
+	"application/xhtml+xml",
+	"application/svg+xml",
 }
 
 // shouldCompress determines if a file should be compressed based on MIME type
@@ -290,23 +698,18 @@ func (vfs *VirtualFileSystem) shouldCompress(mimeType string, size int64) bool {
 		return false
 	}
 
-	if size < compressionThreshold {
+	threshold := vfs.options.CompressionThreshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+	if size < threshold {
 		return false // Too small to benefit
 	}
 
-	// Compress text-based files
-	compressibleTypes := []string{
-		"text/",
-		"application/json",
-		"application/xml",
-		"application/javascript",
-		"application/x-javascript",
-		"application/ecmascript",
-		"application/rss+xml",
-		"application/xhtml+xml",
-		"application/svg+xml",
+	compressibleTypes := vfs.options.CompressibleMimeTypes
+	if len(compressibleTypes) == 0 {
+		compressibleTypes = defaultCompressibleTypes
 	}
-
 	for _, prefix := range compressibleTypes {
 		if strings.HasPrefix(mimeType, prefix) {
 			return true
@@ -316,6 +719,42 @@ func (vfs *VirtualFileSystem) shouldCompress(mimeType string, size int64) bool {
 	return false
 }
 
+// IsHidden reports whether a file or folder name is a dotfile
+func IsHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// loadRoot builds the path filter and loads the source folder
+func (vfs *VirtualFileSystem) loadRoot() error {
+	filter, err := NewPathFilter(vfs.rootPath, vfs.options.Exclude)
+	if err != nil {
+		return fmt.Errorf("failed to read ignore rules: %w", err)
+	}
+	vfs.filter = filter
+	permissions, err := readPermissions(vfs.rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to read permissions: %w", err)
+	}
+	vfs.permissions = permissions
+
+	if err := vfs.loadFolder(vfs.rootPath, ""); err != nil {
+		return fmt.Errorf("failed to load folder into VFS: %w", err)
+	}
+	if vfs.excluded > 0 {
+		vfs.logger().Info("skipped entries matched by ignore rules", "count", vfs.excluded)
+	}
+	vfs.reportManifestMissing()
+	return nil
+}
+
+// PathFilter returns the Exclude and .previewerignore rules the VFS was
+// loaded with
+func (vfs *VirtualFileSystem) PathFilter() *PathFilter {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	return vfs.filter
+}
+
 // loadFolder recursively loads files from disk into memory with encryption
 func (vfs *VirtualFileSystem) loadFolder(basePath, relativePath string) error {
 	fullPath := filepath.Join(basePath, relativePath)
@@ -329,10 +768,30 @@ func (vfs *VirtualFileSystem) loadFolder(basePath, relativePath string) error {
 		entryPath := filepath.Join(fullPath, entry.Name())
 		entryRelPath := filepath.Join(relativePath, entry.Name())
 
+		// The permissions sidecar configures the VFS; it is not content
+		if relativePath == "" && entry.Name() == PermissionsFileName {
+			continue
+		}
+
+		// Skip hidden files and folders unless asked to include them,
+		// matching the folder tree
+		if IsHidden(entry.Name()) && !vfs.options.IncludeHidden {
+			vfs.skipFile(entryRelPath, SkipHidden)
+			continue
+		}
+
+		// Skip entries matched by Exclude or .previewerignore
+		if vfs.filter.Excluded(entryRelPath, entry.IsDir()) {
+			vfs.excluded++
+			vfs.skipFile(entryRelPath, SkipExcluded)
+			continue
+		}
+
 		if entry.IsDir() {
 			// Recursively load subdirectories
 			if err := vfs.loadFolder(basePath, entryRelPath); err != nil {
-				log.Printf("warning: skipping folder %s: %v", entry.Name(), err)
+				vfs.logger().Warn("skipping folder", "path", entryRelPath, "error", err)
+				vfs.skipFile(entryRelPath, SkipUnreadable+": "+err.Error())
 			}
 			continue
 		}
@@ -340,110 +799,159 @@ func (vfs *VirtualFileSystem) loadFolder(basePath, relativePath string) error {
 		// Load file into memory
 		info, err := entry.Info()
 		if err != nil {
-			log.Printf("warning: skipping file %s: %v", entry.Name(), err)
+			vfs.logger().Warn("skipping file", "path", entryRelPath, "error", err)
+			vfs.skipFile(entryRelPath, SkipUnreadable+": "+err.Error())
 			continue
 		}
 
 		// Check file size limit (use configured limit)
 		if info.Size() > vfs.options.MaxFileSize {
-			log.Printf("warning: skipping file %s: exceeds max size (%d MB)",
-				entry.Name(), vfs.options.MaxFileSize/(1024*1024))
+			vfs.logger().Warn("skipping file: exceeds max size",
+				"path", entryRelPath, "max_mb", vfs.options.MaxFileSize/(1024*1024))
+			vfs.skipFile(entryRelPath, SkipTooLarge)
 			continue
 		}
 
 		// Check total size limit (use configured limit)
 		if vfs.totalSize+info.Size() > vfs.options.MaxTotalSize {
-			log.Printf("warning: stopping file loading: total size limit reached (%d MB)",
-				vfs.options.MaxTotalSize/(1024*1024))
+			vfs.logger().Warn("stopping file loading: total size limit reached",
+				"max_mb", vfs.options.MaxTotalSize/(1024*1024))
+			vfs.skipFile(entryRelPath, SkipTotalLimit)
 			return nil
 		}
 
 		// Read file content
 		data, err := os.ReadFile(entryPath)
 		if err != nil {
-			log.Printf("warning: skipping file %s: %v", entry.Name(), err)
+			vfs.logger().Warn("skipping file", "path", entryRelPath, "error", err)
+			vfs.skipFile(entryRelPath, SkipUnreadable+": "+err.Error())
 			continue
 		}
 
-		// Calculate hash of ORIGINAL content for integrity verification
-		hash := sha256.Sum256(data)
-		hashStr := hex.EncodeToString(hash[:])
-
-		// Calculate HMAC of original content
-		hmacStr := vfs.calculateHMAC(data)
-
-		// Detect MIME type before processing
-		mimeType := mime.TypeByExtension(filepath.Ext(entry.Name()))
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
-		}
-
-		// Optionally compress before encryption
-		dataToEncrypt := data
-		isCompressed := false
-		if vfs.shouldCompress(mimeType, info.Size()) {
-			compressed, err := vfs.compressData(data)
-			if err != nil {
-				log.Printf("warning: compression failed for %s: %v", entry.Name(), err)
-			} else if len(compressed) < len(data) {
-				// Only use compression if it actually reduces size
-				dataToEncrypt = compressed
-				isCompressed = true
-				log.Printf("Compressed %s: %d -> %d bytes (%.1f%%)",
-					entry.Name(), len(data), len(compressed),
-					100.0*float64(len(compressed))/float64(len(data)))
-			}
+		if err := vfs.storeFile(entryRelPath, data, info.ModTime()); errors.Is(err, errMimeTypeBlocked) {
+			vfs.logger().Warn("skipping file: MIME type not allowed", "path", entryRelPath)
+			vfs.skipFile(entryRelPath, SkipMimeType)
+			continue
+		} else if errors.Is(err, errManifestMismatch) {
+			vfs.logger().Warn("skipping file: does not match the manifest", "path", entryRelPath)
+			vfs.skipFile(entryRelPath, SkipManifest)
+			continue
+		} else if err != nil {
+			vfs.logger().Warn("skipping file: encryption failed", "path", entryRelPath, "error", err)
+			vfs.skipFile(entryRelPath, SkipEncryption+": "+err.Error())
+			continue
 		}
+	}
+
+	return nil
+}
+
+// storeFile runs data through the hash, HMAC, compression and encryption
+// pipeline and adds it to the VFS under relPath
+func (vfs *VirtualFileSystem) storeFile(relPath string, data []byte, modTime time.Time) error {
+	name := filepath.Base(relPath)
+
+	// Calculate hash of ORIGINAL content for integrity verification
+	hash := sha256.Sum256(data)
+	hashStr := hex.EncodeToString(hash[:])
+	if err := vfs.checkManifest(relPath, hashStr); err != nil {
+		return err
+	}
+
+	// Calculate HMAC of original content
+	hmacStr := vfs.calculateHMAC(data)
+
+	// Detect MIME type before processing; blocked types are never stored
+	mimeType := vfs.detectMimeType(relPath, data)
+	if !vfs.mimeTypeAllowed(mimeType) {
+		return errMimeTypeBlocked
+	}
+	if vfs.dryRun {
+		vfs.files[relPath] = &VirtualFile{Path: relPath, Name: name, Size: int64(len(data)), MimeType: mimeType, Hash: hashStr, ModTime: modTime}
+		vfs.totalSize += int64(len(data))
+		return nil
+	}
 
-		// Encrypt the data (compressed or original)
-		encryptedData, err := vfs.encryptData(dataToEncrypt)
+	permissions := vfs.permissionsFor(relPath)
+
+	// Optionally compress before encryption
+	dataToEncrypt := data
+	isCompressed := false
+	if vfs.shouldCompress(mimeType, int64(len(data))) {
+		compressed, err := vfs.compressData(data)
 		if err != nil {
-			log.Printf("warning: skipping file %s: encryption failed: %v", entry.Name(), err)
-			continue
+			vfs.logger().Warn("compression failed", "path", relPath, "error", err)
+		} else if len(compressed) < len(data) {
+			// Only use compression if it actually reduces size
+			dataToEncrypt = compressed
+			isCompressed = true
+			vfs.logger().Debug("compressed file",
+				"path", relPath, "original_bytes", len(data), "compressed_bytes", len(compressed),
+				"ratio_pct", fmt.Sprintf("%.1f", 100.0*float64(len(compressed))/float64(len(data))))
 		}
+	}
 
-		// Store in VFS with encrypted data
-		vfile := &VirtualFile{
-			Path:         entryRelPath,
-			Name:         entry.Name(),
-			Data:         encryptedData, // Store encrypted (possibly compressed)
-			Size:         info.Size(),   // Original size
-			MimeType:     mimeType,
-			Hash:         hashStr,
-			HMAC:         hmacStr,
-			ModTime:      info.ModTime(),
-			CreatedAt:    time.Now(),
-			isEncrypted:  true,
-			isCompressed: isCompressed,
-			Permissions: &acl.ItemPermissions{
-				CanRead:   true,
-				CanWrite:  false,
-				CanDelete: false,
-			},
-			AccessCount: 0,
-		}
+	// Encrypt the data (compressed or original)
+	encryptedData, err := vfs.encryptData(dataToEncrypt)
+	if err != nil {
+		return err
+	}
 
-		vfs.files[entryRelPath] = vfile
-		vfs.totalSize += info.Size()
+	// Store in VFS with encrypted data
+	vfile := &VirtualFile{
+		Path:         relPath,
+		Name:         name,
+		Data:         encryptedData,    // Store encrypted (possibly compressed)
+		Size:         int64(len(data)), // Original size
+		MimeType:     mimeType,
+		Hash:         hashStr,
+		HMAC:         hmacStr,
+		ModTime:      modTime,
+		CreatedAt:    time.Now(),
+		isEncrypted:  true,
+		isCompressed: isCompressed,
+		Permissions: &permissions,
+		AccessCount: 0,
 	}
 
+	vfs.files[relPath] = vfile
+	vfs.totalSize += int64(len(data))
+
+	// Files that deny reading are never found, so they are not indexed
+	if vfs.options.EnableSearchIndex && isSearchable(mimeType) && permissions.CanRead {
+		vfs.indexFile(relPath, data)
+	}
 	return nil
 }
 
 // ValidatePath ensures the path is safe and doesn't escape the sandbox
 func (vfs *VirtualFileSystem) ValidatePath(path string) error {
+	cleaned, pattern, err := checkPath(path)
+	if pattern != "" {
+		vfs.logSecurityIncident("path_injection", "high", "Suspicious path pattern detected", map[string]any{
+			"path":    path,
+			"pattern": pattern,
+			"cleaned": cleaned,
+		})
+	}
+	return err
+}
+
+// checkPath runs the ValidatePath checks. It returns the cleaned path and,
+// for paths refused for a suspicious character, that character.
+func checkPath(path string) (cleaned, pattern string, err error) {
 	// Check path length to prevent buffer overflow attacks
 	if len(path) > maxPathLength {
-		return fmt.Errorf("invalid path: exceeds maximum length")
+		return "", "", fmt.Errorf("invalid path: exceeds maximum length")
 	}
 
 	// Check for null bytes (path injection attack)
 	if strings.Contains(path, "\x00") {
-		return fmt.Errorf("invalid path: contains null byte")
+		return "", "", fmt.Errorf("invalid path: contains null byte")
 	}
 
 	// Remove leading slashes first (VFS paths are always relative)
-	cleaned := strings.TrimPrefix(path, "/")
+	cleaned = strings.TrimPrefix(path, "/")
 	cleaned = strings.TrimPrefix(cleaned, "\\")
 
 	// Normalize path
@@ -451,12 +959,12 @@ func (vfs *VirtualFileSystem) ValidatePath(path string) error {
 
 	// Check for path traversal attempts
 	if strings.Contains(cleaned, "..") {
-		return fmt.Errorf("invalid path: contains '..'")
+		return cleaned, "", fmt.Errorf("invalid path: contains '..'")
 	}
 
 	// After removing leading slashes, check if it's still absolute (shouldn't be)
 	if filepath.IsAbs(cleaned) {
-		return fmt.Errorf("invalid path: absolute paths not allowed")
+		return cleaned, "", fmt.Errorf("invalid path: absolute paths not allowed")
 	}
 
 	// Check for suspicious patterns
@@ -472,22 +980,22 @@ func (vfs *VirtualFileSystem) ValidatePath(path string) error {
 	}
 	for _, pattern := range suspicious {
 		if strings.Contains(cleaned, pattern) {
-			logSecurityIncident("path_injection", "high", "Suspicious path pattern detected", map[string]any{
-				"path":    path,
-				"pattern": pattern,
-				"cleaned": cleaned,
-			})
-			return fmt.Errorf("invalid path: contains suspicious characters")
+			return cleaned, pattern, fmt.Errorf("invalid path: contains suspicious characters")
 		}
 	}
 
-	return nil
+	return cleaned, "", nil
 }
 
 // trackAccess records file access for anomaly detection
 func (vfs *VirtualFileSystem) trackAccess(path string, success bool, ipAddr string) {
+	ipAddr = vfs.AnonymizeIP(ipAddr)
+	vfs.auditAccess(path, ipAddr, success)
 	vfs.accessMu.Lock()
 	defer vfs.accessMu.Unlock()
+	if vfs.accessLog == nil {
+		return // Cleaned up
+	}
 
 	record, exists := vfs.accessLog[path]
 	if !exists {
@@ -504,15 +1012,16 @@ func (vfs *VirtualFileSystem) trackAccess(path string, success bool, ipAddr stri
 		record.AccessCount++
 	} else {
 		record.FailedAttempts++
+		vfs.lockFileOnFailure(record)
 	}
 
 	if ipAddr != "" {
-		record.IPAddresses[ipAddr]++
+		vfs.recordIP(record, ipAddr)
 	}
 
 	// Anomaly detection
 	if record.FailedAttempts > 10 {
-		logSecurityIncident("excessive_failures", "medium", "Excessive failed access attempts", map[string]any{
+		vfs.logSecurityIncident("excessive_failures", "medium", "Excessive failed access attempts", map[string]any{
 			"path":            path,
 			"failed_attempts": record.FailedAttempts,
 			"ip_addresses":    record.IPAddresses,
@@ -521,7 +1030,7 @@ func (vfs *VirtualFileSystem) trackAccess(path string, success bool, ipAddr stri
 	}
 
 	if record.AccessCount > vfs.options.MaxAccessPerFile {
-		logSecurityIncident("excessive_access", "medium", "Excessive access to file", map[string]any{
+		vfs.logSecurityIncident("excessive_access", "medium", "Excessive access to file", map[string]any{
 			"path":          path,
 			"access_count":  record.AccessCount,
 			"limit":         vfs.options.MaxAccessPerFile,
@@ -533,14 +1042,89 @@ func (vfs *VirtualFileSystem) trackAccess(path string, success bool, ipAddr stri
 	// Calculate anomaly score
 	record.AnomalyScore = vfs.calculateAnomalyScore(record)
 	if record.AnomalyScore > float64(vfs.options.AnomalyThreshold) {
-		logSecurityIncident("anomaly_detected", "high", "High anomaly score detected", map[string]any{
+		vfs.logSecurityIncident("anomaly_detected", "high", "High anomaly score detected", map[string]any{
 			"path":              path,
 			"anomaly_score":     record.AnomalyScore,
 			"threshold":         vfs.options.AnomalyThreshold,
 			"suspicious_flags": record.SuspiciousFlags,
 			"access_count":      record.AccessCount,
 			"failed_attempts":   record.FailedAttempts,
-			"unique_ips":        len(record.IPAddresses),
+			"unique_ips":        record.UniqueIPs,
+		})
+		vfs.strikeIP(ipAddr)
+	}
+}
+
+// maxIPsPerRecord bounds FileAccessRecord.IPAddresses, so a file hammered
+// with spoofed X-Forwarded-For values cannot grow it without limit
+const maxIPsPerRecord = 64
+
+// recordIP counts an access from ip. Once IPAddresses is full, a new IP
+// replaces one seen only once, so repeat visitors stay tracked; if there is
+// none, the access is only counted in OtherIPAccesses. Called with accessMu
+// held.
+func (vfs *VirtualFileSystem) recordIP(record *FileAccessRecord, ip string) {
+	if _, seen := record.IPAddresses[ip]; seen {
+		record.IPAddresses[ip]++
+		return
+	}
+	record.UniqueIPs++
+	if len(record.IPAddresses) < maxIPsPerRecord {
+		record.IPAddresses[ip] = 1
+		return
+	}
+
+	if !slices.Contains(record.SuspiciousFlags, "ip_flood") {
+		vfs.logSecurityIncident("ip_flood", "high", "Too many distinct IPs accessing file", map[string]any{
+			"path":       record.Path,
+			"unique_ips": record.UniqueIPs,
+			"limit":      maxIPsPerRecord,
+		})
+		record.SuspiciousFlags = append(record.SuspiciousFlags, "ip_flood")
+	}
+	for old, n := range record.IPAddresses {
+		if n == 1 {
+			delete(record.IPAddresses, old)
+			record.OtherIPAccesses++
+			record.IPAddresses[ip] = 1
+			return
+		}
+	}
+	record.OtherIPAccesses++
+}
+
+// maxTrackedClients bounds the per-client invalid path counters
+const maxTrackedClients = 1024
+
+// trackInvalidAccess counts a request for a path that failed validation or
+// is not in the VFS. The path is attacker-controlled, so it is not used as a
+// key; counts are kept per client, and clients beyond maxTrackedClients are
+// only counted in total.
+func (vfs *VirtualFileSystem) trackInvalidAccess(ipAddr string) {
+	if ipAddr == "" {
+		ipAddr = "unknown"
+	}
+
+	vfs.accessMu.Lock()
+	defer vfs.accessMu.Unlock()
+	if vfs.invalidAccess == nil {
+		return // Cleaned up
+	}
+
+	count, tracked := vfs.invalidAccess[ipAddr]
+	if !tracked && len(vfs.invalidAccess) >= maxTrackedClients {
+		vfs.invalidOverflow++
+		return
+	}
+	count++
+	vfs.invalidAccess[ipAddr] = count
+	vfs.lockClientOnFailure(ipAddr, count)
+
+	// Report once when a client crosses the threshold, then every hundred
+	if count == 11 || count%100 == 0 {
+		vfs.logSecurityIncident("excessive_failures", "medium", "Excessive requests for invalid or unknown paths", map[string]any{
+			"ip":              ipAddr,
+			"failed_attempts": count,
 		})
 	}
 }
@@ -569,7 +1153,7 @@ func (vfs *VirtualFileSystem) calculateAnomalyScore(record *FileAccessRecord) fl
 	}
 
 	// Factor 3: IP diversity (0-20 points)
-	uniqueIPs := len(record.IPAddresses)
+	uniqueIPs := record.UniqueIPs
 	if uniqueIPs > 5 {
 		// Many IPs accessing same file is suspicious
 		score += math.Min(float64(uniqueIPs-5)*2.0, 20.0)
@@ -609,117 +1193,227 @@ func (vfs *VirtualFileSystem) checkRateLimit(path string) error {
 	return nil
 }
 
-// ReadFile reads and decrypts a file from the VFS with full security checks
+// ReadFile reads and decrypts a file from the VFS with full security checks.
+// Data is a fresh plaintext buffer owned by the caller, who should clear it
+// once done rather than leave it for the garbage collector. It reads as an
+// anonymous, in-process caller, exempt from IP rules, bans and client locks;
+// use ReadFileContext with the client's address when serving a client.
 func (vfs *VirtualFileSystem) ReadFile(path string) (*VirtualFile, error) {
 	return vfs.ReadFileWithIP(path, "")
 }
 
+// Bytes returns the verified plaintext of a file, with the same security
+// checks and access tracking as ReadFile
+func (vfs *VirtualFileSystem) Bytes(path string) ([]byte, error) {
+	vfile, err := vfs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return vfile.Data, nil
+}
+
+// Stat returns a file's metadata without decrypting it; see StatWithIP
+func (vfs *VirtualFileSystem) Stat(path string) (FileInfo, error) {
+	return vfs.StatWithIP(path, "")
+}
+
 // ReadFileWithIP reads file with IP tracking for anomaly detection
 func (vfs *VirtualFileSystem) ReadFileWithIP(path string, ipAddr string) (*VirtualFile, error) {
-	// Validate path
+	return vfs.ReadFileContext(context.Background(), path, ipAddr)
+}
+
+// ReadFileContext is ReadFileWithIP with cancellation: ctx is checked before
+// each expensive step (decrypt, decompress, verify) so a read for a client
+// that has gone away is abandoned early. A cancelled read returns ctx.Err()
+// and is not counted as a failed access.
+func (vfs *VirtualFileSystem) ReadFileContext(ctx context.Context, path string, ipAddr string) (*VirtualFile, error) {
+	vfile, _, err := vfs.readFile(ctx, path, ipAddr, false)
+	return vfile, err
+}
+
+// ReadFileGzip is ReadFileContext for clients that accept gzip: when the file
+// was stored compressed, Data is the gzip stream as stored and the returned
+// bool is true, so it can be sent with Content-Encoding: gzip. Integrity is
+// still verified, by streaming the decompressed bytes through the HMAC and
+// hash without buffering them.
+func (vfs *VirtualFileSystem) ReadFileGzip(ctx context.Context, path string, ipAddr string) (*VirtualFile, bool, error) {
+	return vfs.readFile(ctx, path, ipAddr, true)
+}
+
+// readFile implements ReadFileContext and ReadFileGzip
+func (vfs *VirtualFileSystem) readFile(ctx context.Context, path string, ipAddr string, keepGzip bool) (*VirtualFile, bool, error) {
+	if err := vfs.checkIP(path, ipAddr); err != nil {
+		return nil, false, err
+	}
+	ipAddr = vfs.AnonymizeIP(ipAddr)
+	if err := vfs.checkBan(ipAddr); err != nil {
+		return nil, false, err
+	}
+	if err := vfs.checkLockout(normalizePath(path), ipAddr); err != nil {
+		return nil, false, err
+	}
+
+	// Validate path. Rejected and unknown paths are counted per client
+	// rather than per path, so they cannot grow the access log.
 	if err := vfs.ValidatePath(path); err != nil {
-		vfs.trackAccess(path, false, ipAddr)
-		return nil, fmt.Errorf("access denied: %w", err)
+		vfs.trackInvalidAccess(ipAddr)
+		return nil, false, fmt.Errorf("%w: %w", ErrAccessDenied, err)
 	}
 
+	// Normalize path for lookup
+	normalizedPath := normalizePath(path)
+
 	// Check rate limiting
-	if err := vfs.checkRateLimit(path); err != nil {
-		vfs.trackAccess(path, false, ipAddr)
+	if err := vfs.checkRateLimit(normalizedPath); err != nil {
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		var accessCount int
 		vfs.accessMu.RLock()
-		record := vfs.accessLog[path]
+		if record := vfs.accessLog[normalizedPath]; record != nil {
+			accessCount = record.AccessCount
+		}
 		vfs.accessMu.RUnlock()
-		logSecurityIncident("rate_limit_exceeded", "medium", "Rate limit exceeded", map[string]any{
+		vfs.logSecurityIncident("rate_limit_exceeded", "medium", "Rate limit exceeded", map[string]any{
 			"path":         path,
 			"ip":           ipAddr,
-			"access_count": record.AccessCount,
+			"access_count": accessCount,
 			"limit":        vfs.options.MaxAccessPerFile,
 			"window":       rateLimitWindow.String(),
 		})
-		return nil, err
+		return nil, false, err
 	}
 
 	vfs.mu.RLock()
 
-	// Normalize path for lookup
-	normalizedPath := filepath.Clean(path)
-	normalizedPath = strings.TrimPrefix(normalizedPath, "/")
-	normalizedPath = strings.TrimPrefix(normalizedPath, "\\")
-
 	vfile, exists := vfs.files[normalizedPath]
 	if !exists {
 		vfs.mu.RUnlock()
-		vfs.trackAccess(path, false, ipAddr)
-		return nil, fmt.Errorf("file not found: %s", path)
+		vfs.trackInvalidAccess(ipAddr)
+		return nil, false, fmt.Errorf("file not found: %s", path)
 	}
 
 	// Check permissions
 	if vfile.Permissions != nil && !vfile.Permissions.CanRead {
 		vfs.mu.RUnlock()
-		vfs.trackAccess(path, false, ipAddr)
-		return nil, fmt.Errorf("access denied: no read permission")
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		return nil, false, fmt.Errorf("%w: no read permission", ErrAccessDenied)
+	}
+
+	// Defense in depth: blocked types should never have been stored
+	if !vfs.mimeTypeAllowed(vfile.MimeType) {
+		mimeType := vfile.MimeType
+		vfs.mu.RUnlock()
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		vfs.logSecurityIncident("blocked_mime_type", "high", "Read of a blocked MIME type refused", map[string]any{
+			"path":      path,
+			"mime_type": mimeType,
+			"ip":        ipAddr,
+		})
+		return nil, false, fmt.Errorf("%w: MIME type not allowed", ErrAccessDenied)
 	}
 
 	// Decrypt data
+	if err := ctx.Err(); err != nil {
+		vfs.mu.RUnlock()
+		return nil, false, err
+	}
 	decryptedData, err := vfs.decryptData(vfile.Data)
 	if err != nil {
 		vfs.mu.RUnlock()
-		vfs.trackAccess(path, false, ipAddr)
-		logSecurityIncident("tampering", "critical", "Decryption failed - possible tampering", map[string]any{
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		vfs.countIntegrityFailure(failDecrypt)
+		vfs.logSecurityIncident("tampering", "critical", "Decryption failed - possible tampering", map[string]any{
 			"path":  path,
 			"error": err.Error(),
 			"ip":    ipAddr,
 		})
-		return nil, fmt.Errorf("data corruption detected")
+		return nil, false, fmt.Errorf("data corruption detected")
 	}
 
-	// Decompress if needed
-	if vfile.isCompressed {
-		decompressedData, err := vfs.decompressData(decryptedData)
+	// Decompress if needed (unless the caller takes the gzip stream as-is)
+	gzipped := keepGzip && vfile.isCompressed
+	if vfile.isCompressed && !gzipped {
+		if err := ctx.Err(); err != nil {
+			vfs.mu.RUnlock()
+			return nil, false, err
+		}
+		decompressedData, err := vfs.decompressData(decryptedData, vfile.Size)
 		if err != nil {
 			vfs.mu.RUnlock()
-			vfs.trackAccess(path, false, ipAddr)
-			logSecurityIncident("data_corruption", "high", "Decompression failed", map[string]any{
+			vfs.trackAccess(normalizedPath, false, ipAddr)
+			vfs.countIntegrityFailure(failDecompress)
+			vfs.logSecurityIncident("data_corruption", "high", "Decompression failed", map[string]any{
 				"path":  path,
 				"error": err.Error(),
 				"ip":    ipAddr,
 			})
-			return nil, fmt.Errorf("data corruption detected")
+			return nil, false, fmt.Errorf("data corruption detected")
 		}
+		zero(decryptedData)
 		decryptedData = decompressedData
 	}
 
 	// Verify HMAC to detect tampering (on original uncompressed data)
-	if !vfs.verifyHMAC(decryptedData, vfile.HMAC) {
+	if err := ctx.Err(); err != nil {
 		vfs.mu.RUnlock()
-		vfs.trackAccess(path, false, ipAddr)
-		logSecurityIncident("tampering", "critical", "HMAC verification failed - TAMPERING DETECTED", map[string]any{
+		return nil, false, err
+	}
+	var hmacOK bool
+	var hashStr string
+	if gzipped {
+		actualHMAC, actualHash, err := vfs.digestGzip(ctx, decryptedData, vfile.Size)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			vfs.mu.RUnlock()
+			return nil, false, ctxErr
+		}
+		if err != nil {
+			vfs.mu.RUnlock()
+			vfs.trackAccess(normalizedPath, false, ipAddr)
+			vfs.countIntegrityFailure(failDecompress)
+			vfs.logSecurityIncident("data_corruption", "high", "Decompression failed", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+				"ip":    ipAddr,
+			})
+			return nil, false, fmt.Errorf("data corruption detected")
+		}
+		hmacOK = hmac.Equal([]byte(actualHMAC), []byte(vfile.HMAC))
+		hashStr = actualHash
+	} else {
+		hmacOK = vfs.verifyHMAC(decryptedData, vfile.HMAC)
+		hash := sha256.Sum256(decryptedData)
+		hashStr = hex.EncodeToString(hash[:])
+	}
+	if !hmacOK {
+		vfs.mu.RUnlock()
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		vfs.countIntegrityFailure(failHMAC)
+		vfs.logSecurityIncident("tampering", "critical", "HMAC verification failed - TAMPERING DETECTED", map[string]any{
 			"path":          path,
 			"ip":            ipAddr,
 			"file_hash":     vfile.Hash,
 			"stored_hmac":   vfile.HMAC,
 		})
-		return nil, fmt.Errorf("tampering detected: HMAC verification failed")
+		return nil, false, fmt.Errorf("tampering detected: HMAC verification failed")
 	}
 
 	// Verify hash integrity (on original uncompressed data)
-	hash := sha256.Sum256(decryptedData)
-	hashStr := hex.EncodeToString(hash[:])
 	if hashStr != vfile.Hash {
 		vfs.mu.RUnlock()
-		vfs.trackAccess(path, false, ipAddr)
-		logSecurityIncident("tampering", "critical", "Hash mismatch - TAMPERING DETECTED", map[string]any{
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		vfs.countIntegrityFailure(failHash)
+		vfs.logSecurityIncident("tampering", "critical", "Hash mismatch - TAMPERING DETECTED", map[string]any{
 			"path":         path,
 			"ip":           ipAddr,
 			"expected_hash": vfile.Hash,
 			"actual_hash":   hashStr,
 		})
-		return nil, fmt.Errorf("tampering detected: hash mismatch")
+		return nil, false, fmt.Errorf("tampering detected: hash mismatch")
 	}
 
 	vfs.mu.RUnlock()
 
 	// Track successful access
-	vfs.trackAccess(path, true, ipAddr)
+	vfs.trackAccess(normalizedPath, true, ipAddr)
 
 	// Return decrypted file data (fully decompressed and verified)
 	return &VirtualFile{
@@ -735,15 +1429,70 @@ func (vfs *VirtualFileSystem) ReadFileWithIP(path string, ipAddr string) (*Virtu
 		AccessCount: vfile.AccessCount + 1,
 		CreatedAt:   vfile.CreatedAt,
 		isEncrypted: false, // Now decrypted
-	}, nil
+		isCompressed: gzipped,
+	}, gzipped, nil
+}
+
+// digestGzip decompresses a gzip stream that should expand to size bytes
+// straight into the HMAC and SHA-256 hashers, returning both as hex without
+// holding the plaintext in memory. Like decompressData it stops at
+// decompressLimit, and it gives up once ctx is done.
+func (vfs *VirtualFileSystem) digestGzip(ctx context.Context, data []byte, size int64) (hmacHex, hashHex string, err error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+	defer reader.Close()
+
+	mac := hmac.New(sha512.New, vfs.hmacKey)
+	sum := sha256.New()
+	digests := io.MultiWriter(mac, sum)
+	limit := vfs.decompressLimit(size)
+	limited := io.LimitReader(reader, limit+1)
+	buf := make([]byte, 32*1024)
+	defer zero(buf)
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", "", err
+		}
+		n, err := limited.Read(buf)
+		digests.Write(buf[:n])
+		total += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if total > limit {
+		vfs.options.Logger.Warn("decompression stopped at the size limit",
+			"compressed_bytes", len(data), "expected_bytes", size, "limit_bytes", limit)
+		return "", "", ErrDecompressionLimit
+	}
+	if total != size {
+		return "", "", fmt.Errorf("decompressed to %d bytes, recorded size is %d", total, size)
+	}
+	return hex.EncodeToString(mac.Sum(nil)), hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// Close calls SecureCleanup, so a VFS can be handed to code that takes an
+// io.Closer. It always returns nil.
+func (vfs *VirtualFileSystem) Close() error {
+	vfs.SecureCleanup()
+	return nil
 }
 
 // SecureCleanup securely wipes encryption keys and sensitive data from memory
+// and stops the background sweeper. It must be called once the VFS is no
+// longer needed; calling it again is harmless.
 func (vfs *VirtualFileSystem) SecureCleanup() {
 	vfs.mu.Lock()
 	defer vfs.mu.Unlock()
 
-	log.Println("VFS: Performing secure cleanup...")
+	vfs.logger().Info("VFS: performing secure cleanup")
+	vfs.stopSweeper()
 
 	// Zero out encryption keys
 	for i := range vfs.encryptionKey {
@@ -760,13 +1509,28 @@ func (vfs *VirtualFileSystem) SecureCleanup() {
 		}
 	}
 
-	// Clear maps
+	// Clear maps. The access state is guarded by accessMu, which requests
+	// still in flight may be about to take.
 	vfs.files = nil
+	vfs.accessMu.Lock()
 	vfs.accessLog = nil
+	vfs.invalidAccess = nil
+	vfs.lockedIPs = nil
+	vfs.ipBans = nil
+	vfs.ipStrikes = nil
+	vfs.accessMu.Unlock()
+	vfs.searchIndex = nil
 
 	runtime.GC() // Force garbage collection
 
-	log.Println("VFS: Secure cleanup completed")
+	vfs.logger().Info("VFS: secure cleanup completed")
+}
+
+// IsClosed reports whether SecureCleanup has run
+func (vfs *VirtualFileSystem) IsClosed() bool {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	return vfs.files == nil
 }
 
 // GetSecurityStats returns security statistics for monitoring
@@ -785,20 +1549,80 @@ func (vfs *VirtualFileSystem) GetSecurityStats() map[string]interface{} {
 			uniqueIPs[ip] = true
 		}
 	}
+	invalidAttempts := vfs.invalidOverflow
+	for _, n := range vfs.invalidAccess {
+		invalidAttempts += n
+	}
+	lockedFiles, lockedClients := vfs.lockCounts(time.Now())
+	bannedClients := 0
+	for _, until := range vfs.ipBans {
+		if time.Now().Before(until) {
+			bannedClients++
+		}
+	}
 
 	fileCount, totalSize := vfs.GetStats()
+	compressedFiles, storedSize := vfs.compressionStats()
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"files_count":       fileCount,
 		"total_size_mb":     float64(totalSize) / (1024 * 1024),
+		"stored_size_mb":    float64(storedSize) / (1024 * 1024),
+		"compressed_files":  compressedFiles,
 		"encrypted":         true,
 		"sealed":            vfs.sealed,
 		"total_accesses":    totalAccesses,
 		"failed_accesses":   totalFailed,
+		"invalid_path_attempts": invalidAttempts,
 		"unique_ips":        len(uniqueIPs),
+		"locked_files":      lockedFiles,
+		"locked_clients":    lockedClients,
+		"banned_clients":    bannedClients,
+		"skipped_files":     len(vfs.SkippedFiles()),
 		"uptime_seconds":    time.Since(vfs.createdAt).Seconds(),
 		"read_only":         vfs.readOnly,
 	}
+	for kind, name := range integrityStatNames {
+		stats[name] = vfs.integrity[kind].Load()
+	}
+	return stats
+}
+
+// AccessRecord returns a copy of the access record for path, combining the
+// records of every spelling of the path ("a.txt", "/a.txt") that was
+// requested. It reports false if the file was never accessed.
+func (vfs *VirtualFileSystem) AccessRecord(path string) (FileAccessRecord, bool) {
+	vfs.accessMu.RLock()
+	defer vfs.accessMu.RUnlock()
+
+	want := normalizePath(path)
+	merged := FileAccessRecord{Path: want, IPAddresses: make(map[string]int)}
+	found := false
+	for key, record := range vfs.accessLog {
+		if normalizePath(key) != want {
+			continue
+		}
+		found = true
+		merged.AccessCount += record.AccessCount
+		merged.FailedAttempts += record.FailedAttempts
+		if merged.FirstAccess.IsZero() || record.FirstAccess.Before(merged.FirstAccess) {
+			merged.FirstAccess = record.FirstAccess
+		}
+		if record.LastAccess.After(merged.LastAccess) {
+			merged.LastAccess = record.LastAccess
+		}
+		for ip, n := range record.IPAddresses {
+			merged.IPAddresses[ip] += n
+		}
+		merged.UniqueIPs += record.UniqueIPs
+		merged.OtherIPAccesses += record.OtherIPAccesses
+		merged.AnomalyScore = math.Max(merged.AnomalyScore, record.AnomalyScore)
+		merged.SuspiciousFlags = append(merged.SuspiciousFlags, record.SuspiciousFlags...)
+		if record.LockedUntil.After(merged.LockedUntil) {
+			merged.LockedUntil = record.LockedUntil
+		}
+	}
+	return merged, found
 }
 
 // FileExists checks if a file exists in the VFS
@@ -810,12 +1634,76 @@ func (vfs *VirtualFileSystem) FileExists(path string) bool {
 	vfs.mu.RLock()
 	defer vfs.mu.RUnlock()
 
+	_, exists := vfs.files[normalizePath(path)]
+	return exists
+}
+
+// Info returns a file's metadata without any access checks or tracking,
+// for operator tooling. It reports false if the file does not exist.
+func (vfs *VirtualFileSystem) Info(path string) (FileInfo, bool) {
+	if err := vfs.ValidatePath(path); err != nil {
+		return FileInfo{}, false
+	}
+
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+
+	vfile, exists := vfs.files[normalizePath(path)]
+	if !exists {
+		return FileInfo{}, false
+	}
+	return vfile.info(), true
+}
+
+// normalizePath converts a request path into the key used in vfs.files
+func normalizePath(path string) string {
 	normalizedPath := filepath.Clean(path)
 	normalizedPath = strings.TrimPrefix(normalizedPath, "/")
 	normalizedPath = strings.TrimPrefix(normalizedPath, "\\")
+	return normalizedPath
+}
 
-	_, exists := vfs.files[normalizedPath]
-	return exists
+// StatWithIP returns a file's metadata without decrypting it. Path validation,
+// rate limiting and read permission are enforced as for ReadFileWithIP; failures
+// are tracked, but a successful stat does not count as an access.
+func (vfs *VirtualFileSystem) StatWithIP(path string, ipAddr string) (FileInfo, error) {
+	if err := vfs.checkIP(path, ipAddr); err != nil {
+		return FileInfo{}, err
+	}
+	ipAddr = vfs.AnonymizeIP(ipAddr)
+	if err := vfs.checkBan(ipAddr); err != nil {
+		return FileInfo{}, err
+	}
+	if err := vfs.checkLockout(normalizePath(path), ipAddr); err != nil {
+		return FileInfo{}, err
+	}
+	if err := vfs.ValidatePath(path); err != nil {
+		vfs.trackInvalidAccess(ipAddr)
+		return FileInfo{}, fmt.Errorf("%w: %w", ErrAccessDenied, err)
+	}
+
+	normalizedPath := normalizePath(path)
+	if err := vfs.checkRateLimit(normalizedPath); err != nil {
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		return FileInfo{}, err
+	}
+
+	vfs.mu.RLock()
+	vfile, exists := vfs.files[normalizedPath]
+	if !exists {
+		vfs.mu.RUnlock()
+		vfs.trackInvalidAccess(ipAddr)
+		return FileInfo{}, fmt.Errorf("file not found: %s", path)
+	}
+	if vfile.Permissions != nil && !vfile.Permissions.CanRead {
+		vfs.mu.RUnlock()
+		vfs.trackAccess(normalizedPath, false, ipAddr)
+		return FileInfo{}, fmt.Errorf("%w: no read permission", ErrAccessDenied)
+	}
+	info := vfile.info()
+	vfs.mu.RUnlock()
+
+	return info, nil
 }
 
 // FileInfo holds public metadata about a file in the VFS (without decrypted data)
@@ -827,6 +1715,8 @@ type FileInfo struct {
 	Hash     string
 	HMAC     string
 	ModTime  time.Time
+	StoredSize int64 // Bytes held in memory: the encrypted, possibly compressed content
+	Compressed bool  // Whether compression made the file smaller, so it was stored gzipped
 }
 
 // ListFiles returns metadata for all files in the VFS
@@ -836,22 +1726,43 @@ func (vfs *VirtualFileSystem) ListFiles() []FileInfo {
 
 	result := make([]FileInfo, 0, len(vfs.files))
 	for _, vf := range vfs.files {
-		result = append(result, FileInfo{
-			Path:     vf.Path,
-			Name:     vf.Name,
-			Size:     vf.Size,
-			MimeType: vf.MimeType,
-			Hash:     vf.Hash,
-			HMAC:     vf.HMAC,
-			ModTime:  vf.ModTime,
-		})
+		result = append(result, vf.info())
 	}
 	return result
 }
 
+// info returns the public metadata of a stored file
+func (vf *VirtualFile) info() FileInfo {
+	return FileInfo{
+		Path:     vf.Path,
+		Name:     vf.Name,
+		Size:     vf.Size,
+		MimeType: vf.MimeType,
+		Hash:     vf.Hash,
+		HMAC:     vf.HMAC,
+		ModTime:  vf.ModTime,
+		StoredSize: int64(len(vf.Data)),
+		Compressed: vf.isCompressed,
+	}
+}
+
 // GetStats returns statistics about the VFS
 func (vfs *VirtualFileSystem) GetStats() (fileCount int, totalSize int64) {
 	vfs.mu.RLock()
 	defer vfs.mu.RUnlock()
 	return len(vfs.files), vfs.totalSize
 }
+
+// compressionStats returns how many files were stored compressed and the
+// bytes all files take in memory
+func (vfs *VirtualFileSystem) compressionStats() (compressedFiles int, storedSize int64) {
+	vfs.mu.RLock()
+	defer vfs.mu.RUnlock()
+	for _, vfile := range vfs.files {
+		if vfile.isCompressed {
+			compressedFiles++
+		}
+		storedSize += int64(len(vfile.Data))
+	}
+	return compressedFiles, storedSize
+}
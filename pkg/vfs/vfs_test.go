@@ -0,0 +1,99 @@
+package vfs
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDeprecatedLogCallbackIsInstanceDefault(t *testing.T) {
+	var global, own atomic.Int32
+	SetLogCallback(func(map[string]any) { global.Add(1) })
+	t.Cleanup(func() {
+		defaultCallbackMu.Lock()
+		securityLogCallback = nil
+		defaultCallbackMu.Unlock()
+	})
+
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	withDefault, err := NewVirtualFileSystemWithOptions(t.TempDir(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withDefault.SecureCleanup()
+	options.LogCallback = func(map[string]any) { own.Add(1) }
+	withOwn, err := NewVirtualFileSystemWithOptions(t.TempDir(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withOwn.SecureCleanup()
+
+	withDefault.logSecurityIncident("test", "low", "default", nil)
+	withOwn.logSecurityIncident("test", "low", "own", nil)
+	if global.Load() != 1 || own.Load() != 1 {
+		t.Fatalf("default callback got %d, own callback got %d; want 1 each", global.Load(), own.Load())
+	}
+
+	// Turning the instance callback off doesn't fall back to the default
+	withDefault.SetLogCallback(nil)
+	withDefault.logSecurityIncident("test", "low", "off", nil)
+	if global.Load() != 1 {
+		t.Fatalf("default callback got %d after SetLogCallback(nil), want 1", global.Load())
+	}
+}
+
+func TestSkippedFileLogsRedacted(t *testing.T) {
+	var logs bytes.Buffer
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	options.RedactPaths = RedactPathsHash
+	options.MaxFileSize = 4
+	fs, err := NewVirtualFileSystemFromMap(map[string][]byte{"patients/jane/report.txt": []byte("too large")}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	if !strings.Contains(logs.String(), "exceeds max size") {
+		t.Fatalf("no skip logged:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), "report.txt") {
+		t.Fatalf("skipped path logged in full:\n%s", logs.String())
+	}
+}
+
+func TestFromMapRecordsSkips(t *testing.T) {
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.MaxFileSize = 4
+	options.MaxTotalSize = 4
+	options.BlockedMimeTypes = []string{"image/*"}
+	fs, err := NewVirtualFileSystemFromMap(map[string][]byte{
+		"a.png": []byte("x"),
+		"b.txt": []byte("too large"),
+		"c.txt": []byte("abcd"),
+		"d.txt": []byte("e"),
+	}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	want := []SkipInfo{
+		{Path: "a.png", Reason: SkipMimeType},
+		{Path: "b.txt", Reason: SkipTooLarge},
+		{Path: "d.txt", Reason: SkipTotalLimit},
+	}
+	got := fs.SkippedFiles()
+	if len(got) != len(want) {
+		t.Fatalf("SkippedFiles() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("SkippedFiles() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultWatchInterval = time.Second
+
+// Reload re-reads the source folder and atomically replaces the VFS
+// contents. Access records and keys are kept; readers see either the old or
+// the new set of files, never a mix. Reload and RotateKeys wait for each
+// other. Incidents found while loading go to this VFS's callback.
+func (vfs *VirtualFileSystem) Reload() error {
+	if vfs.rootPath == "" {
+		return fmt.Errorf("VFS has no source folder to reload")
+	}
+
+	vfs.rekeyMu.Lock()
+	defer vfs.rekeyMu.Unlock()
+
+	// The staging VFS encrypts with copies of the keys, which stay intact
+	// even if SecureCleanup wipes the originals meanwhile
+	vfs.mu.RLock()
+	staging := &VirtualFileSystem{
+		rootPath:      vfs.rootPath,
+		files:         make(map[string]*VirtualFile),
+		readOnly:      true,
+		encryptionKey: bytes.Clone(vfs.encryptionKey),
+		hmacKey:       bytes.Clone(vfs.hmacKey),
+		keySalt:       vfs.keySalt,
+		ipSalt:        vfs.ipSalt,
+		ipRules:       vfs.ipRules,
+		manifest:      vfs.manifest,
+		options:       vfs.options,
+	}
+	cleaned := vfs.files == nil
+	vfs.mu.RUnlock()
+	defer zero(staging.encryptionKey)
+	defer zero(staging.hmacKey)
+	if cleaned {
+		return fmt.Errorf("VFS has been cleaned up")
+	}
+	vfs.logMu.RLock()
+	staging.logCallback = vfs.logCallback
+	vfs.logMu.RUnlock()
+
+	if err := staging.loadRoot(); err != nil {
+		return err
+	}
+
+	vfs.mu.Lock()
+	defer vfs.mu.Unlock()
+	if vfs.files == nil {
+		return fmt.Errorf("VFS has been cleaned up")
+	}
+	for _, vfile := range vfs.files {
+		zero(vfile.Data)
+	}
+	vfs.files = staging.files
+	vfs.totalSize = staging.totalSize
+	vfs.searchIndex = staging.searchIndex
+	vfs.filter = staging.filter
+	vfs.skipped = staging.skipped
+	vfs.permissions = staging.permissions
+
+	vfs.logger().Info("VFS reloaded",
+		"files", len(vfs.files),
+		"total_size_mb", float64(vfs.totalSize)/(1024*1024))
+	return nil
+}
+
+// Watch polls the source folder every interval (default 1s) and calls Reload
+// followed by onChange when files are added, removed or modified. Changes are
+// debounced: the reload waits until the folder has been stable for a full
+// interval, so a bulk save triggers a single reload. Watch blocks until ctx
+// is done.
+func (vfs *VirtualFileSystem) Watch(ctx context.Context, interval time.Duration, onChange func()) error {
+	if vfs.rootPath == "" {
+		return fmt.Errorf("VFS has no source folder to watch")
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	current := folderSignature(vfs.rootPath, vfs.options.IncludeHidden, vfs.PathFilter())
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		sig := folderSignature(vfs.rootPath, vfs.options.IncludeHidden, vfs.PathFilter())
+		if sig != current {
+			current = sig
+			pending = true
+			continue
+		}
+		if !pending {
+			continue
+		}
+		pending = false
+
+		if err := vfs.Reload(); err != nil {
+			vfs.logger().Warn("VFS reload failed", "error", err)
+			continue
+		}
+		if onChange != nil {
+			onChange()
+		}
+	}
+}
+
+// folderSignature digests the path, size and modification time of every
+// file under root that loadFolder would load, plus the ignore and
+// permissions files
+func folderSignature(root string, includeHidden bool, filter *PathFilter) [sha256.Size]byte {
+	h := sha256.New()
+	for _, name := range []string{IgnoreFileName, PermissionsFileName} {
+		if info, err := os.Stat(filepath.Join(root, name)); err == nil {
+			fmt.Fprintf(h, "%s\x00%d\x00%d\n", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if (IsHidden(d.Name()) && !includeHidden) || filter.Excluded(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,95 @@
+package vfs
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReloadDuringRotateKeys(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 20 {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.txt", i))
+		if err := os.WriteFile(name, []byte(fmt.Sprintf("file %d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	var incidents []string
+	var incidentsMu sync.Mutex
+	options.LogCallback = func(data map[string]any) {
+		incidentsMu.Lock()
+		defer incidentsMu.Unlock()
+		incidents = append(incidents, fmt.Sprint(data["incident_type"]))
+	}
+	fs, err := NewVirtualFileSystemWithOptions(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := fs.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := fs.RotateKeys(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range 20 {
+		name := fmt.Sprintf("f%02d.txt", i)
+		vfile, err := fs.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read %s after reload and rotation: %v", name, err)
+		}
+		if got, want := string(vfile.Data), fmt.Sprintf("file %d", i); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	incidentsMu.Lock()
+	defer incidentsMu.Unlock()
+	if len(incidents) != 0 {
+		t.Errorf("unexpected incidents: %v", incidents)
+	}
+}
+
+func TestReloadIncidentsUseInstanceCallback(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.Logger = slog.New(slog.DiscardHandler)
+	options.Manifest = map[string]string{"a.txt": "0000000000000000000000000000000000000000000000000000000000000000"}
+	var got []string
+	options.LogCallback = func(data map[string]any) {
+		got = append(got, fmt.Sprint(data["incident_type"]))
+	}
+	fs, err := NewVirtualFileSystemWithOptions(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.SecureCleanup()
+
+	before := len(got)
+	if err := fs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == before {
+		t.Error("manifest mismatch during Reload did not reach the instance callback")
+	}
+}
@@ -0,0 +1,239 @@
+package vfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Encrypted zip entries
+//
+// Two schemes are read: traditional PKWARE encryption ("zip -e", ZipCrypto)
+// and WinZip AES (method 99, AE-1 and AE-2, as written by 7-Zip and most
+// archivers). Each entry is decrypted and checked in memory; the plaintext
+// then goes through the VFS pipeline like any other file, under the VFS's
+// own keys.
+
+// ErrArchivePasswordRequired is returned for an encrypted archive entry when
+// Options.ArchivePassword is empty
+var ErrArchivePasswordRequired = errors.New("archive is encrypted: a password is required")
+
+// ErrArchivePasswordWrong is returned when an archive entry does not decrypt
+// with Options.ArchivePassword
+var ErrArchivePasswordWrong = errors.New("wrong archive password")
+
+const (
+	zipFlagEncrypted      = 0x1
+	zipFlagDataDescriptor = 0x8
+	zipMethodAES          = 99
+	zipExtraAES           = 0x9901
+	zipCryptoHeaderLen    = 12
+	zipAESVerifierLen     = 2
+	zipAESAuthLen         = 10
+	zipAESIterations      = 1000
+)
+
+// readZipEntry returns the content of an archive entry, decrypting it with
+// password when it is encrypted. At most limit bytes are decompressed.
+func readZipEntry(f *zip.File, password string, limit int64) ([]byte, error) {
+	if f.Flags&zipFlagEncrypted == 0 {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return readLimited(rc, limit)
+	}
+	if password == "" {
+		return nil, ErrArchivePasswordRequired
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := readLimited(raw, limit+zipCryptoHeaderLen+32)
+	if err != nil {
+		return nil, err
+	}
+
+	method := f.Method
+	checkCRC := true
+	var compressed []byte
+	if f.Method == zipMethodAES {
+		var ae2 bool
+		compressed, method, ae2, err = decryptAES(f, ciphertext, password)
+		// AE-2 entries store no CRC; the HMAC already authenticated them
+		checkCRC = !ae2
+	} else {
+		compressed, err = decryptZipCrypto(f, ciphertext, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	switch method {
+	case zip.Store:
+		if int64(len(compressed)) > limit {
+			return nil, ErrDecompressionLimit
+		}
+		content = compressed
+	case zip.Deflate:
+		fr := flate.NewReader(bytes.NewReader(compressed))
+		defer fr.Close()
+		if content, err = readLimited(fr, limit); err != nil {
+			// Garbage from a password that slipped past the check byte
+			if !errors.Is(err, ErrDecompressionLimit) {
+				return nil, ErrArchivePasswordWrong
+			}
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d", method)
+	}
+	if checkCRC && crc32.ChecksumIEEE(content) != f.CRC32 {
+		return nil, ErrArchivePasswordWrong
+	}
+	return content, nil
+}
+
+// readLimited reads r to the end, failing with ErrDecompressionLimit past
+// limit bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrDecompressionLimit
+	}
+	return data, nil
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE stream cipher
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+	return k
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(b byte) byte {
+	t := k[2] | 2
+	b ^= byte((t * (t ^ 1)) >> 8)
+	k.update(b)
+	return b
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+// decryptZipCrypto decrypts a ZipCrypto entry. The last byte of the
+// 12-byte header must match the CRC (or, for streamed entries, the
+// modification time), which rejects all but 1 in 256 wrong passwords; the
+// CRC of the content catches the rest.
+func decryptZipCrypto(f *zip.File, ciphertext []byte, password string) ([]byte, error) {
+	if len(ciphertext) < zipCryptoHeaderLen {
+		return nil, zip.ErrFormat
+	}
+	keys := newZipCryptoKeys(password)
+	plaintext := make([]byte, len(ciphertext))
+	for i, b := range ciphertext {
+		plaintext[i] = keys.decrypt(b)
+	}
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&zipFlagDataDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if plaintext[zipCryptoHeaderLen-1] != check {
+		return nil, ErrArchivePasswordWrong
+	}
+	return plaintext[zipCryptoHeaderLen:], nil
+}
+
+// decryptAES decrypts a WinZip AES entry after checking the password
+// verifier and the HMAC-SHA1 authentication code. It returns the entry's
+// real compression method and whether it is AE-2.
+func decryptAES(f *zip.File, ciphertext []byte, password string) ([]byte, uint16, bool, error) {
+	version, strength, method, ok := zipAESExtra(f.Extra)
+	if !ok || strength < 1 || strength > 3 {
+		return nil, 0, false, fmt.Errorf("%w: bad AES extra field", zip.ErrFormat)
+	}
+	keyLen := 8 + 8*int(strength) // 16, 24 or 32 bytes
+	saltLen := keyLen / 2
+	if len(ciphertext) < saltLen+zipAESVerifierLen+zipAESAuthLen {
+		return nil, 0, false, zip.ErrFormat
+	}
+	salt := ciphertext[:saltLen]
+	verifier := ciphertext[saltLen : saltLen+zipAESVerifierLen]
+	data := ciphertext[saltLen+zipAESVerifierLen : len(ciphertext)-zipAESAuthLen]
+	authCode := ciphertext[len(ciphertext)-zipAESAuthLen:]
+
+	derived, err := pbkdf2.Key(sha1.New, password, salt, zipAESIterations, 2*keyLen+zipAESVerifierLen)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("derive archive key: %w", err)
+	}
+	encKey, macKey := derived[:keyLen], derived[keyLen:2*keyLen]
+	if subtle.ConstantTimeCompare(derived[2*keyLen:], verifier) != 1 {
+		return nil, 0, false, ErrArchivePasswordWrong
+	}
+	mac := hmac.New(sha1.New, macKey)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:zipAESAuthLen], authCode) {
+		return nil, 0, false, fmt.Errorf("archive entry %s: authentication failed", f.Name)
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	plaintext := make([]byte, len(data))
+	// WinZip's CTR mode uses a little-endian block counter starting at 1
+	var counter, stream [aes.BlockSize]byte
+	for off, n := 0, uint64(1); off < len(data); off, n = off+aes.BlockSize, n+1 {
+		binary.LittleEndian.PutUint64(counter[:], n)
+		block.Encrypt(stream[:], counter[:])
+		end := min(off+aes.BlockSize, len(data))
+		subtle.XORBytes(plaintext[off:end], data[off:end], stream[:end-off])
+	}
+	return plaintext, method, version == 2, nil
+}
+
+// zipAESExtra reads the 0x9901 extra field of a WinZip AES entry
+func zipAESExtra(extra []byte) (version uint16, strength byte, method uint16, ok bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			return 0, 0, 0, false
+		}
+		if id == zipExtraAES && size >= 7 {
+			field := extra[:size]
+			return binary.LittleEndian.Uint16(field), field[4], binary.LittleEndian.Uint16(field[5:]), true
+		}
+		extra = extra[size:]
+	}
+	return 0, 0, 0, false
+}